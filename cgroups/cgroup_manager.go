@@ -1,6 +1,7 @@
 package cgroups
 
 import (
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/seagullbird/mydocker/cgroups/subsystems"
 )
@@ -16,19 +17,21 @@ func NewCgroupManager(path string) *CgroupManager {
 	}
 }
 
+// Apply stops at the first subsystem failing to take the process in
 func (c *CgroupManager) Apply(pid int, res *subsystems.ResourceConfig) error {
 	for _, subSysIns := range subsystems.SubsystemsIns {
 		if err := subSysIns.Apply(c.Path, pid, res); err != nil {
-			log.Errorf("Applying cgroup error: %v", err)
+			return fmt.Errorf("Applying cgroup %s error: %v", subSysIns.Name(), err)
 		}
 	}
 	return nil
 }
 
+// Set stops at the first subsystem failing to set its limit
 func (c *CgroupManager) Set(res *subsystems.ResourceConfig) error {
 	for _, subSysIns := range subsystems.SubsystemsIns {
 		if err := subSysIns.Set(c.Path, res); err != nil {
-			log.Errorf("Setting cgroup error: %v", err)
+			return fmt.Errorf("Setting cgroup %s error: %v", subSysIns.Name(), err)
 		}
 	}
 	return nil
@@ -36,9 +39,32 @@ func (c *CgroupManager) Set(res *subsystems.ResourceConfig) error {
 
 func (c *CgroupManager) Destroy() error {
 	for _, subSysIns := range subsystems.SubsystemsIns {
+		// destroyed already when the container died unseen
+		if !subsystems.CgroupExists(subSysIns, c.Path) {
+			continue
+		}
 		if err := subSysIns.Remove(c.Path); err != nil {
 			log.Warnf("remove cgroup fail %v", err)
 		}
 	}
 	return nil
 }
+
+// GetPids lists every process in the cgroup, the memory subsystem is
+// always joined by Apply
+func (c *CgroupManager) GetPids() ([]int, error) {
+	return subsystems.GetCgroupProcs("memory", c.Path)
+}
+
+func (c *CgroupManager) Freeze() error {
+	return subsystems.Freezer.Freeze(c.Path)
+}
+
+func (c *CgroupManager) Thaw() error {
+	return subsystems.Freezer.Thaw(c.Path)
+}
+
+// OOMKillCount tells how many processes of the cgroup were killed out of memory
+func (c *CgroupManager) OOMKillCount() (int, error) {
+	return subsystems.Memory.OOMKillCount(c.Path)
+}
@@ -36,8 +36,8 @@ func (s *CpuSubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) er
 		return nil
 	}
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
-		// write process pid into tasks
-		if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		// write process pid into cgroup.procs, tasks would only move one thread
+		if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
 			return fmt.Errorf("set cgroup proc fail %v", err)
 		}
 		return nil
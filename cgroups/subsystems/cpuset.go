@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 type CpusetSubSystem struct {
@@ -17,15 +18,9 @@ func (s *CpusetSubSystem) Name() string {
 }
 
 func (s *CpusetSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
-	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+	if subsysCgroupPath, err := s.initCgroup(cgroupPath); err == nil {
 		if res.CpuSet != "" {
 			log.Infof("Setting cpuset limit: %v", res.CpuSet)
-			// cpuset.mems also need to be configured
-			// https://www.richardhsu.me/posts/2014/12/08/cgroups-and-no-space.html
-			// https://access.redhat.com/documentation/en-us/red_hat_enterprise_linux/6/html/resource_management_guide/sec-cpuset
-			if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, "cpuset.mems"), []byte("0"), 0644); err != nil {
-				return fmt.Errorf("set cgroup cpuset.mems fail %v", err)
-			}
 			// write cpuset limit into cpuset.cpus
 			if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, "cpuset.cpus"), []byte(res.CpuSet), 0644); err != nil {
 				return fmt.Errorf("set cgroup cpuset.cpus fail %v", err)
@@ -37,13 +32,44 @@ func (s *CpusetSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	}
 }
 
+// initCgroup creates the cgroup and every missing parent of it. A new
+// cpuset starts with empty cpuset.cpus and cpuset.mems and no process can
+// join it, so each one is given the cpus and mems of its parent.
+// https://www.richardhsu.me/posts/2014/12/08/cgroups-and-no-space.html
+func (s *CpusetSubSystem) initCgroup(cgroupPath string) (string, error) {
+	subsysCgroupPath := FindCgroupMountPoint(s.Name())
+	for _, name := range strings.Split(strings.Trim(filepath.Clean(cgroupPath), "/"), "/") {
+		parent := subsysCgroupPath
+		subsysCgroupPath = filepath.Join(parent, name)
+		if err := os.Mkdir(subsysCgroupPath, 0755); err != nil && !os.IsExist(err) {
+			return "", fmt.Errorf("Create cgroup %s error: %v", subsysCgroupPath, err)
+		}
+		for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+			content, err := ioutil.ReadFile(filepath.Join(subsysCgroupPath, file))
+			if err != nil {
+				return "", fmt.Errorf("read cgroup %s fail %v", file, err)
+			}
+			if strings.TrimSpace(string(content)) != "" {
+				continue
+			}
+			if content, err = ioutil.ReadFile(filepath.Join(parent, file)); err != nil {
+				return "", fmt.Errorf("read cgroup %s fail %v", file, err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, file), content, 0644); err != nil {
+				return "", fmt.Errorf("set cgroup %s fail %v", file, err)
+			}
+		}
+	}
+	return subsysCgroupPath, nil
+}
+
 func (s *CpusetSubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) error {
 	if res.CpuSet == "" {
 		return nil
 	}
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
-		// write process pid into tasks
-		if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		// write process pid into cgroup.procs, tasks would only move one thread
+		if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
 			return fmt.Errorf("set cgroup proc fail %v", err)
 		}
 		return nil
@@ -0,0 +1,113 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FreezerSubSystem freezes and thaws every process of a cgroup. The v1
+// freezer hierarchy is used when mounted, cgroup v2 otherwise.
+type FreezerSubSystem struct {
+}
+
+func (s *FreezerSubSystem) Name() string {
+	return "freezer"
+}
+
+// path returns the freezer cgroup dir and whether it is a v2 cgroup
+func (s *FreezerSubSystem) path(cgroupPath string, autoCreate bool) (string, bool, error) {
+	if FindCgroupMountPoint(s.Name()) != "" {
+		subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, autoCreate)
+		return subsysCgroupPath, false, err
+	}
+	cgroupRoot := FindCgroup2MountPoint()
+	if cgroupRoot == "" {
+		return "", false, fmt.Errorf("No freezer cgroup mounted")
+	}
+	cgroupAbsPath := filepath.Join(cgroupRoot, cgroupPath)
+	if _, err := os.Stat(cgroupAbsPath); err != nil {
+		if !autoCreate || !os.IsNotExist(err) {
+			return "", true, fmt.Errorf("cgroup path error %v", err)
+		}
+		if err := os.MkdirAll(cgroupAbsPath, 0755); err != nil {
+			return "", true, fmt.Errorf("Create cgroup %s error: %v", cgroupAbsPath, err)
+		}
+	}
+	return cgroupAbsPath, true, nil
+}
+
+func (s *FreezerSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	_, _, err := s.path(cgroupPath, true)
+	return err
+}
+
+// Apply always joins the freezer cgroup, any container may be paused
+func (s *FreezerSubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) error {
+	subsysCgroupPath, _, err := s.path(cgroupPath, false)
+	if err != nil {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("set cgroup proc fail %v", err)
+	}
+	return nil
+}
+
+func (s *FreezerSubSystem) Remove(cgroupPath string) error {
+	subsysCgroupPath, _, err := s.path(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.Remove(subsysCgroupPath)
+}
+
+// Freeze stops every process of the cgroup and waits until all are frozen
+func (s *FreezerSubSystem) Freeze(cgroupPath string) error {
+	return s.setFrozen(cgroupPath, true)
+}
+
+// Thaw resumes the processes of a frozen cgroup
+func (s *FreezerSubSystem) Thaw(cgroupPath string) error {
+	return s.setFrozen(cgroupPath, false)
+}
+
+func (s *FreezerSubSystem) setFrozen(cgroupPath string, frozen bool) error {
+	subsysCgroupPath, v2, err := s.path(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	// v1 writes FROZEN or THAWED to freezer.state, which reads FREEZING
+	// until every task is frozen. v2 writes 1 or 0 to cgroup.freeze and
+	// reports "frozen 1" in cgroup.events once done.
+	file, value, stateFile, want := "freezer.state", "THAWED", "freezer.state", "THAWED"
+	if frozen {
+		value, want = "FROZEN", "FROZEN"
+	}
+	if v2 {
+		file, value, stateFile, want = "cgroup.freeze", "0", "cgroup.events", "frozen 0"
+		if frozen {
+			value, want = "1", "frozen 1"
+		}
+	}
+	for retry := 0; retry < 1000; retry++ {
+		if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, file), []byte(value), 0644); err != nil {
+			return fmt.Errorf("set cgroup %s fail %v", file, err)
+		}
+		content, err := ioutil.ReadFile(filepath.Join(subsysCgroupPath, stateFile))
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.TrimSpace(line) == want {
+				return nil
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("Timeout waiting for cgroup %s to become %s", cgroupPath, want)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 type MemorySubSystem struct {
@@ -31,13 +32,12 @@ func (s *MemorySubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	}
 }
 
+// Apply always joins the memory cgroup, it tracks every process of
+// the container even without a limit
 func (s *MemorySubSystem) Apply(cgroupPath string, pid int, res *ResourceConfig) error {
-	if res.MemoryLimit == "" {
-		return nil
-	}
 	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
-		// write process pid into tasks
-		if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		// write process pid into cgroup.procs, tasks would only move one thread
+		if err := ioutil.WriteFile(filepath.Join(subsysCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
 			return fmt.Errorf("set cgroup proc fail %v", err)
 		}
 		return nil
@@ -53,3 +53,22 @@ func (s *MemorySubSystem) Remove(cgroupPath string) error {
 		return err
 	}
 }
+
+// OOMKillCount reads how many processes of the cgroup the OOM killer has killed
+func (s *MemorySubSystem) OOMKillCount(cgroupPath string) (int, error) {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return 0, err
+	}
+	content, err := ioutil.ReadFile(filepath.Join(subsysCgroupPath, "memory.oom_control"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.Atoi(fields[1])
+		}
+	}
+	return 0, fmt.Errorf("oom_kill not found in %s", subsysCgroupPath)
+}
@@ -16,7 +16,10 @@ type Subsystem interface {
 var (
 	SubsystemsIns = []Subsystem{
 		&CpusetSubSystem{},
-		&MemorySubSystem{},
+		Memory,
 		&CpuSubSystem{},
+		Freezer,
 	}
+	Memory  = &MemorySubSystem{}
+	Freezer = &FreezerSubSystem{}
 )
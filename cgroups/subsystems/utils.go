@@ -3,8 +3,10 @@ package subsystems
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -33,12 +35,33 @@ func FindCgroupMountPoint(subsystem string) string {
 	return ""
 }
 
+// FindCgroup2MountPoint returns where the unified cgroup v2 hierarchy is mounted
+func FindCgroup2MountPoint() string {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), " ")
+		// the filesystem type follows the " - " separator
+		for i := 6; i < len(fields)-1; i++ {
+			if fields[i] == "-" && fields[i+1] == "cgroup2" {
+				return fields[4]
+			}
+		}
+	}
+	return ""
+}
+
 func GetCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string, error) {
 	cgroupRoot := FindCgroupMountPoint(subsystem)
 	cgroupAbsPath := filepath.Join(cgroupRoot, cgroupPath)
 	if _, err := os.Stat(cgroupAbsPath); err == nil || (autoCreate && os.IsNotExist(err)) {
 		if os.IsNotExist(err) {
-			if err := os.Mkdir(cgroupAbsPath, 0755); err == nil {
+			if err := os.MkdirAll(cgroupAbsPath, 0755); err == nil {
 			} else {
 				return "", fmt.Errorf("Create cgroup %s error: %v", cgroupAbsPath, err)
 			}
@@ -48,3 +71,35 @@ func GetCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string
 		return "", fmt.Errorf("cgroup path error %v", err)
 	}
 }
+
+// CgroupExists tells whether the cgroup of a subsystem is there, the
+// freezer cgroup lives in the v2 hierarchy without a v1 freezer
+func CgroupExists(subsystem Subsystem, cgroupPath string) bool {
+	if freezer, ok := subsystem.(*FreezerSubSystem); ok {
+		_, _, err := freezer.path(cgroupPath, false)
+		return err == nil
+	}
+	_, err := GetCgroupPath(subsystem.Name(), cgroupPath, false)
+	return err == nil
+}
+
+// GetCgroupProcs lists the processes in a cgroup of the subsystem
+func GetCgroupProcs(subsystem string, cgroupPath string) ([]int, error) {
+	subsysCgroupPath, err := GetCgroupPath(subsystem, cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(filepath.Join(subsysCgroupPath, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, line := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pid %s in %s", line, subsysCgroupPath)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
@@ -0,0 +1,225 @@
+package container
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const maxSymlinks = 255
+
+// ResolveInRoot resolves path as if root were "/": symlinks are followed
+// but ".." and absolute link targets never lead outside of root.
+// Missing components are kept as they are.
+func ResolveInRoot(root, path string) (string, error) {
+	current := "/"
+	links := 0
+	for path != "" {
+		var part string
+		if i := strings.IndexByte(path, '/'); i == -1 {
+			part, path = path, ""
+		} else {
+			part, path = path[:i], path[i+1:]
+		}
+		if part == "" || part == "." {
+			continue
+		}
+		if part == ".." {
+			current = filepath.Dir(current)
+			continue
+		}
+		next := filepath.Join(current, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return "", err
+			}
+			current = next
+			continue
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+		links++
+		if links > maxSymlinks {
+			return "", &os.PathError{Op: "resolve", Path: filepath.Join(root, next), Err: syscall.ELOOP}
+		}
+		dest, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(dest) {
+			current = "/"
+		}
+		path = dest + "/" + path
+	}
+	return filepath.Join(root, current), nil
+}
+
+// Untar extracts a tar stream into dir. A top level entry named rename
+// is extracted as to instead. It is meant to run chrooted into the
+// container root so that links inside the container cannot point outside.
+func Untar(r io.Reader, dir, rename, to string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(filepath.Join("/", hdr.Name), "/")
+		if rename != "" && (name == rename || strings.HasPrefix(name, rename+"/")) {
+			name = to + name[len(rename):]
+		}
+		if name == "" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		info := hdr.FileInfo()
+
+		if existing, err := os.Lstat(path); err == nil && !(existing.IsDir() && info.IsDir()) {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(path, info.Mode().Perm()); err != nil && !os.IsExist(err) {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tr)
+			file.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			target := strings.TrimPrefix(filepath.Join("/", hdr.Linkname), "/")
+			if rename != "" && (target == rename || strings.HasPrefix(target, rename+"/")) {
+				target = to + target[len(rename):]
+			}
+			if err := os.Link(filepath.Join(dir, target), path); err != nil {
+				return err
+			}
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			mode := uint32(info.Mode().Perm())
+			switch hdr.Typeflag {
+			case tar.TypeChar:
+				mode |= syscall.S_IFCHR
+			case tar.TypeBlock:
+				mode |= syscall.S_IFBLK
+			case tar.TypeFifo:
+				mode |= syscall.S_IFIFO
+			}
+			if err := syscall.Mknod(path, mode, mkdev(hdr.Devmajor, hdr.Devminor)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unsupported tar entry %s of type %c", hdr.Name, hdr.Typeflag)
+		}
+
+		if err := os.Lchown(path, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeSymlink {
+			// chmod again, Mkdir and OpenFile are subject to umask
+			if err := os.Chmod(path, info.Mode()); err != nil {
+				return err
+			}
+			atime := hdr.AccessTime
+			if atime.IsZero() {
+				atime = hdr.ModTime
+			}
+			if err := os.Chtimes(path, atime, hdr.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Tar writes path and everything below it as a tar stream, entries are
+// named relative to the parent of path. Symlinks are stored, never
+// followed. Like Untar it is meant to run chrooted into the container root.
+func Tar(w io.Writer, path string) error {
+	tw := tar.NewWriter(w)
+	base := filepath.Dir(path)
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(base, file)
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.CopyN(tw, f, hdr.Size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func mkdev(major, minor int64) int {
+	return int((minor & 0xff) | ((major & 0xfff) << 8) | ((minor &^ 0xff) << 12) | ((major &^ 0xfff) << 32))
+}
+
+// IsMounted tells whether something is mounted on path
+func IsMounted(path string) (bool, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	path = filepath.Clean(path)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), " ")
+		if len(fields) > 4 && fields[4] == path {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,131 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveInRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "mydocker-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	os.MkdirAll(filepath.Join(root, "usr/lib"), 0755)
+	os.Symlink("/etc", filepath.Join(root, "abs"))
+	os.Symlink("../../..", filepath.Join(root, "usr/lib/up"))
+	os.Symlink("lib", filepath.Join(root, "usr/rel"))
+	os.Symlink("loop", filepath.Join(root, "loop"))
+
+	cases := map[string]string{
+		"/etc/passwd":          "/etc/passwd",
+		"../../etc":            "/etc",
+		"/abs/hosts":           "/etc/hosts",
+		"/usr/lib/up/etc":      "/etc",
+		"/usr/lib/up/up/../..": "/",
+		"/usr/rel/x":           "/usr/lib/x",
+		"missing/../etc":       "/etc",
+	}
+	for path, want := range cases {
+		got, err := ResolveInRoot(root, path)
+		if err != nil {
+			t.Errorf("ResolveInRoot(%s) error: %v", path, err)
+			continue
+		}
+		if got != filepath.Join(root, want) {
+			t.Errorf("ResolveInRoot(%s) = %s, want %s", path, got, filepath.Join(root, want))
+		}
+	}
+	if _, err := ResolveInRoot(root, "/loop"); err == nil {
+		t.Error("expected symlink loop to fail")
+	}
+}
+
+func TestUntar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mydocker-untar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	entries := []*tar.Header{
+		{Name: "src/", Typeflag: tar.TypeDir, Mode: 0750},
+		{Name: "src/file", Typeflag: tar.TypeReg, Mode: 0640, Size: 5},
+		{Name: "src/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		{Name: "src/hard", Typeflag: tar.TypeLink, Linkname: "src/file"},
+		{Name: "../other", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+	}
+	for _, hdr := range entries {
+		hdr.Uid, hdr.Gid = os.Getuid(), os.Getgid()
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte("hello"))
+		}
+	}
+	tw.Close()
+
+	if err := Untar(&buf, dir, "src", "dst"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "dst")); err != nil || !info.IsDir() || info.Mode().Perm() != 0750 {
+		t.Errorf("dst is not a 0750 directory: %v %v", info, err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "dst/file")); err != nil || string(content) != "hello" {
+		t.Errorf("dst/file = %q, %v", content, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dir, "dst/link")); err != nil || link != "/etc/passwd" {
+		t.Errorf("dst/link = %q, %v", link, err)
+	}
+	file, _ := os.Stat(filepath.Join(dir, "dst/file"))
+	hard, err := os.Stat(filepath.Join(dir, "dst/hard"))
+	if err != nil || !os.SameFile(file, hard) {
+		t.Errorf("dst/hard is not a hard link of dst/file: %v", err)
+	}
+	// ".." cannot leave dir
+	if _, err := os.Stat(filepath.Join(dir, "other")); err != nil {
+		t.Errorf("other not extracted into dir: %v", err)
+	}
+}
+
+func TestTarCopiesOut(t *testing.T) {
+	root, err := ioutil.TempDir("", "mydocker-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "data/sub"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "data/sub/file"), []byte("hello"), 0600)
+	// a link out of the container is copied as a link, never followed
+	os.Symlink("/etc", filepath.Join(root, "data/etc"))
+
+	var buf bytes.Buffer
+	if err := Tar(&buf, filepath.Join(root, "data")); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := ioutil.TempDir("", "mydocker-dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	if err := Untar(&buf, dst, "data", "copy"); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dst, "copy/sub/file")); err != nil || string(content) != "hello" {
+		t.Errorf("copy/sub/file = %q, %v", content, err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "copy/sub/file")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("copy/sub/file mode = %v, %v", info, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "copy/etc")); err != nil || link != "/etc" {
+		t.Errorf("copy/etc = %q, %v", link, err)
+	}
+}
@@ -2,7 +2,7 @@ package container
 
 import (
 	"fmt"
-	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/cgroups/subsystems"
 	"net"
 	"os"
 	"os/exec"
@@ -11,33 +11,87 @@ import (
 )
 
 type ContainerInfo struct {
-	Pid         string   `json:"pid"`
-	Id          string   `json:"id"`
-	Name        string   `json:"name"`
-	Command     string   `json:"command"`
-	CreatedTime string   `json:"createTime"`
-	Status      string   `json:"status"`
-	Volume      string   `json:"volume"`
-	Image       string   `json:"image"`
-	Network     string   `json:"network"`
-	IPAddress   net.IP   `json:"ip"`
-	PortMapping []string `json:"portmapping"`
+	Pid         int               `json:"pid"`
+	Id          string            `json:"id"`
+	Name        string            `json:"name"`
+	Command     string            `json:"command"`
+	Args        []string          `json:"args"`
+	Env         []string          `json:"env"`
+	Tty         bool              `json:"tty"`
+	CreatedTime string            `json:"createTime"`
+	Status      string            `json:"status"`
+	Mounts      []*Mount          `json:"mounts"`
+	Image       string            `json:"image"`
+	Network     string            `json:"network"`
+	IPAddress   net.IP            `json:"ip"`
+	PortMapping []string          `json:"portmapping"`
+	ReadOnly    bool              `json:"readOnly"`
+	Tmpfs       []string          `json:"tmpfs"`
+	StorageOpt  map[string]string `json:"storageOpt"`
+	StopSignal  string            `json:"stopSignal,omitempty"`
+	WorkingDir  string            `json:"workingDir,omitempty"`
+	User        string            `json:"user,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	StartedAt   string            `json:"startedAt,omitempty"`
+	FinishedAt  string            `json:"finishedAt,omitempty"`
+	ExitCode    int               `json:"exitCode"`
+	OOMKilled   bool              `json:"oomKilled"`
+	Ulimits     []*Rlimit         `json:"ulimits,omitempty"`
+	// start times tell the processes from later ones reusing their pids
+	PidStartTime uint64 `json:"pidStartTime,omitempty"`
+	// pid of the mydocker process monitoring the container
+	MonitorPid       int            `json:"monitorPid,omitempty"`
+	MonitorStartTime uint64         `json:"monitorStartTime,omitempty"`
+	RestartPolicy    *RestartPolicy `json:"restartPolicy,omitempty"`
+	RestartCount     int            `json:"restartCount"`
+	ManuallyStopped  bool           `json:"manuallyStopped"`
+	// remove the container once it has exited for good
+	AutoRemove  bool          `json:"autoRemove"`
+	Healthcheck *HealthConfig `json:"healthcheck,omitempty"`
+	Health      *Health       `json:"health,omitempty"`
+	// resource limits applied on every start
+	Resources *subsystems.ResourceConfig `json:"resources"`
+	// the single -v spec and its driver of records written before Mounts,
+	// moved into Mounts when the record is loaded
+	Volume       string `json:"volume,omitempty"`
+	VolumeDriver string `json:"volumeDriver,omitempty"`
 }
 
 var (
+	CREATED             string = "created"
 	RUNNING             string = "running"
+	PAUSED              string = "paused"
+	RESTARTING          string = "restarting"
 	STOP                string = "stopped"
 	Exit                string = "exited"
 	ConfigName          string = "config.json"
+	ConfigLockName      string = "config.lock"
+	WorkspaceLockName   string = "workspace.lock"
 	ContainerLogFile    string = "container.log"
-	RootDir             string = "/var/lib/mydocker/"
-	DefaultInfoLocation string = filepath.Join(RootDir, "containers/%s/")
-	LayerDir            string = filepath.Join(RootDir, "overlay2/%s/")
-	MntDir              string = filepath.Join(RootDir, "overlay2/%s/merged/")
-	WriteLayerDir       string = filepath.Join(RootDir, "overlay2/%s/write_layer/")
-	WorkDir             string = filepath.Join(RootDir, "overlay2/%s/work/%s/")
+	ShimLogFile         string = "shim.log"
+	DefaultRootDir      string = "/var/lib/mydocker/"
+	RootDir             string
+	DefaultInfoLocation string
+	LayerDir            string
+	MntDir              string
+	WriteLayerDir       string
+	WorkDir             string
 )
 
+func init() {
+	SetRootDir(DefaultRootDir)
+}
+
+// SetRootDir relocates all container state under root
+func SetRootDir(root string) {
+	RootDir = root
+	DefaultInfoLocation = filepath.Join(RootDir, "containers/%s/")
+	LayerDir = filepath.Join(RootDir, "overlay2/%s/")
+	MntDir = filepath.Join(RootDir, "overlay2/%s/merged/")
+	WriteLayerDir = filepath.Join(RootDir, "overlay2/%s/write_layer/")
+	WorkDir = filepath.Join(RootDir, "overlay2/%s/work/%s/")
+}
+
 func layerPath(imageName string) string {
 	return fmt.Sprintf(LayerDir, imageName)
 }
@@ -46,7 +100,7 @@ func ContainerMntPath(containerName string) string {
 	return fmt.Sprintf(MntDir, containerName)
 }
 
-func containerWriteLayerPath(containerName string) string {
+func ContainerWriteLayerPath(containerName string) string {
 	return fmt.Sprintf(WriteLayerDir, containerName)
 }
 
@@ -54,20 +108,19 @@ func containerWorkPath(containerName, sub string) string {
 	return fmt.Sprintf(WorkDir, containerName, sub)
 }
 
-func NewParentProcess(tty bool, volume, containerName, imageName string, envSlice []string) (*exec.Cmd, *os.File) {
-	readPipe, writePipe, err := NewPipe()
-	if err != nil {
-		log.Errorf("New pipe error %v", err)
-		return nil, nil
-	}
-
+// NewParentProcess prepares the init process of a container whose
+// workspace has been set up by NewWorkSpace. The log file the process
+// writes to, nil with a tty, is for the caller to close once it started.
+func NewParentProcess(containerInfo *ContainerInfo) (*exec.Cmd, *InitPipe, *os.File, error) {
+	containerName := containerInfo.Name
 	cmd := exec.Command("/proc/self/exe", "init")
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Cloneflags: syscall.CLONE_NEWIPC | syscall.CLONE_NEWNET |
 			syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWUTS,
 	}
 
-	if tty {
+	var stdLogFile *os.File
+	if containerInfo.Tty {
 		cmd.Stdout = os.Stdout
 		cmd.Stdin = os.Stdin
 		cmd.Stderr = os.Stderr
@@ -75,23 +128,28 @@ func NewParentProcess(tty bool, volume, containerName, imageName string, envSlic
 		// save container stdout
 		containerInfoDir := fmt.Sprintf(DefaultInfoLocation, containerName)
 		if err := os.MkdirAll(containerInfoDir, 0622); err != nil {
-			log.Errorf("NewParentProcess mkdir %s error: %v", containerInfoDir, err)
-			return nil, nil
+			return nil, nil, nil, fmt.Errorf("Mkdir %s error: %v", containerInfoDir, err)
 		}
 		stdLogFilePath := filepath.Join(containerInfoDir, ContainerLogFile)
-		stdLogFile, err := os.Create(stdLogFilePath)
+		// a restarted container keeps its previous output
+		var err error
+		stdLogFile, err = os.OpenFile(stdLogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
-			log.Errorf("NewParentProcess create file %s error: %v", stdLogFilePath, err)
-			return nil, nil
+			return nil, nil, nil, fmt.Errorf("Open file %s error: %v", stdLogFilePath, err)
 		}
 		cmd.Stdout = stdLogFile
 	}
 
+	initPipe, err := newInitPipe()
+	if err != nil {
+		if stdLogFile != nil {
+			stdLogFile.Close()
+		}
+		return nil, nil, nil, fmt.Errorf("New pipe error: %v", err)
+	}
 	cmd.Dir = ContainerMntPath(containerName)
-	cmd.ExtraFiles = []*os.File{readPipe}
-	cmd.Env = append(os.Environ(), envSlice...)
-	NewWorkSpace(volume, imageName, containerName)
-	return cmd, writePipe
+	cmd.ExtraFiles = initPipe.childFiles
+	return cmd, initPipe, stdLogFile, nil
 }
 
 func NewPipe() (*os.File, *os.File, error) {
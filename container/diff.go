@@ -0,0 +1,130 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+type ChangeKind int
+
+const (
+	ChangeModify ChangeKind = iota
+	ChangeAdd
+	ChangeDelete
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeModify:
+		return "C"
+	case ChangeAdd:
+		return "A"
+	case ChangeDelete:
+		return "D"
+	}
+	return ""
+}
+
+type Change struct {
+	Path string
+	Kind ChangeKind
+}
+
+// ContainerChanges reports what the container wrote on top of its image
+func ContainerChanges(containerName, imageName string) ([]Change, error) {
+	return changes(ContainerWriteLayerPath(containerName), layerPath(imageName))
+}
+
+// changes walks an overlay upper dir and compares it with its lower dir.
+// A 0/0 character device is a whiteout hiding the lower file, an opaque
+// directory hides everything the lower dir holds at that path.
+func changes(upperDir, lowerDir string) ([]Change, error) {
+	var result []Change
+	err := filepath.Walk(upperDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(upperDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		changePath := filepath.Join("/", rel)
+		lowerPath := filepath.Join(lowerDir, rel)
+
+		if isWhiteout(info) {
+			result = append(result, Change{Path: changePath, Kind: ChangeDelete})
+			return nil
+		}
+		lowerInfo, err := os.Lstat(lowerPath)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			result = append(result, Change{Path: changePath, Kind: ChangeAdd})
+			return nil
+		}
+		result = append(result, Change{Path: changePath, Kind: ChangeModify})
+
+		if info.IsDir() && lowerInfo.IsDir() && isOpaque(path) {
+			// lower entries not re-created in the upper dir are gone
+			deleted, err := opaqueDeletes(path, lowerPath, changePath)
+			if err != nil {
+				return err
+			}
+			result = append(result, deleted...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
+
+func opaqueDeletes(upperPath, lowerPath, changePath string) ([]Change, error) {
+	var result []Change
+	err := filepath.Walk(lowerPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(lowerPath, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if _, err := os.Lstat(filepath.Join(upperPath, rel)); err == nil {
+			return nil
+		}
+		result = append(result, Change{Path: filepath.Join(changePath, rel), Kind: ChangeDelete})
+		if info.IsDir() {
+			// report the directory, not its whole content
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return result, err
+}
+
+func isWhiteout(info os.FileInfo) bool {
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
+
+func isOpaque(path string) bool {
+	// user.overlay.* is used when overlay is mounted with userxattr
+	for _, attr := range []string{"trusted.overlay.opaque", "user.overlay.opaque"} {
+		value := make([]byte, 1)
+		n, err := syscall.Getxattr(path, attr, value)
+		if err == nil && n == 1 && value[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
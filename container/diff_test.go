@@ -0,0 +1,59 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mydocker-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lower := filepath.Join(dir, "lower")
+	upper := filepath.Join(dir, "upper")
+
+	for _, d := range []string{"lower/etc", "lower/opt/app", "lower/var", "upper/etc", "upper/opt", "upper/tmp"} {
+		os.MkdirAll(filepath.Join(dir, d), 0755)
+	}
+	for _, f := range []string{"lower/etc/hosts", "lower/etc/passwd", "lower/opt/app/bin", "lower/opt/keep", "upper/etc/hosts", "upper/opt/keep", "upper/tmp/new"} {
+		ioutil.WriteFile(filepath.Join(dir, f), []byte("x"), 0644)
+	}
+	if err := syscall.Mknod(filepath.Join(upper, "etc/passwd"), syscall.S_IFCHR, 0); err != nil {
+		t.Skipf("cannot create whiteout: %v", err)
+	}
+	if err := syscall.Mknod(filepath.Join(upper, "var"), syscall.S_IFCHR, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Setxattr(filepath.Join(upper, "opt"), "trusted.overlay.opaque", []byte("y"), 0); err != nil {
+		t.Skipf("cannot set opaque xattr: %v", err)
+	}
+
+	got, err := changes(upper, lower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"C /etc",
+		"C /etc/hosts",
+		"D /etc/passwd",
+		"C /opt",
+		"D /opt/app",
+		"C /opt/keep",
+		"A /tmp",
+		"A /tmp/new",
+		"D /var",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("changes = %v, want %v", got, want)
+	}
+	for i, c := range got {
+		if c.Kind.String()+" "+c.Path != want[i] {
+			t.Errorf("change %d = %s %s, want %s", i, c.Kind, c.Path, want[i])
+		}
+	}
+}
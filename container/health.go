@@ -0,0 +1,138 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	HealthStarting  = "starting"
+	Healthy         = "healthy"
+	Unhealthy       = "unhealthy"
+	healthLogLength = 5
+	// output kept from each probe
+	healthOutputLength = 4096
+
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthTimeout  = 30 * time.Second
+	defaultHealthRetries  = 3
+)
+
+// HealthConfig is the HEALTHCHECK of an image or container, in the shape
+// of the Healthcheck section of `docker inspect`. Test is ["NONE"],
+// ["CMD", args...] or ["CMD-SHELL", command]. Zero values are unset.
+type HealthConfig struct {
+	Test        []string      `json:"Test"`
+	Interval    time.Duration `json:"Interval"`
+	Timeout     time.Duration `json:"Timeout"`
+	StartPeriod time.Duration `json:"StartPeriod"`
+	Retries     int           `json:"Retries"`
+}
+
+// Health is the health state of a running container
+type Health struct {
+	Status        string          `json:"status"`
+	FailingStreak int             `json:"failingStreak"`
+	Log           []*HealthResult `json:"log"`
+}
+
+type HealthResult struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	ExitCode int    `json:"exitCode"`
+	Output   string `json:"output"`
+}
+
+// MergeHealthConfig fills the unset fields of config from the image's
+// config and the defaults, it returns nil when there is no check to run
+func MergeHealthConfig(config, image *HealthConfig) (*HealthConfig, error) {
+	if config == nil && image == nil {
+		return nil, nil
+	}
+	merged := &HealthConfig{}
+	if image != nil {
+		*merged = *image
+	}
+	if config != nil {
+		if len(config.Test) > 0 {
+			merged.Test = config.Test
+		}
+		if config.Interval != 0 {
+			merged.Interval = config.Interval
+		}
+		if config.Timeout != 0 {
+			merged.Timeout = config.Timeout
+		}
+		if config.StartPeriod != 0 {
+			merged.StartPeriod = config.StartPeriod
+		}
+		if config.Retries != 0 {
+			merged.Retries = config.Retries
+		}
+	}
+	if len(merged.Test) == 0 || merged.Test[0] == "NONE" {
+		return nil, nil
+	}
+	if _, err := merged.Command(); err != nil {
+		return nil, err
+	}
+	if merged.Interval == 0 {
+		merged.Interval = defaultHealthInterval
+	}
+	if merged.Timeout == 0 {
+		merged.Timeout = defaultHealthTimeout
+	}
+	if merged.Retries == 0 {
+		merged.Retries = defaultHealthRetries
+	}
+	return merged, nil
+}
+
+// Command is the shell command running the probe
+func (c *HealthConfig) Command() (string, error) {
+	if len(c.Test) < 2 {
+		return "", fmt.Errorf("Invalid healthcheck test: %v", c.Test)
+	}
+	switch c.Test[0] {
+	case "CMD":
+		// every argument stays one word for the shell
+		args := make([]string, len(c.Test)-1)
+		for i, arg := range c.Test[1:] {
+			args[i] = shellQuote(arg)
+		}
+		return strings.Join(args, " "), nil
+	case "CMD-SHELL":
+		return strings.Join(c.Test[1:], " "), nil
+	}
+	return "", fmt.Errorf("Invalid healthcheck test: %v", c.Test)
+}
+
+// shellQuote quotes s as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// Record adds the result of a probe run after the start period when
+// counts is set, failures within the start period are not counted
+func (h *Health) Record(result *HealthResult, counts bool, retries int) {
+	if len(result.Output) > healthOutputLength {
+		result.Output = result.Output[:healthOutputLength]
+	}
+	h.Log = append(h.Log, result)
+	if len(h.Log) > healthLogLength {
+		h.Log = h.Log[len(h.Log)-healthLogLength:]
+	}
+	if result.ExitCode == 0 {
+		h.Status = Healthy
+		h.FailingStreak = 0
+		return
+	}
+	if !counts {
+		return
+	}
+	h.FailingStreak++
+	if h.FailingStreak >= retries {
+		h.Status = Unhealthy
+	}
+}
@@ -0,0 +1,63 @@
+package container
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestMergeHealthConfig(t *testing.T) {
+	image := &HealthConfig{Test: []string{"CMD", "check"}, Interval: time.Second}
+	merged, err := MergeHealthConfig(&HealthConfig{Retries: 5}, image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd, _ := merged.Command(); cmd != "'check'" || merged.Interval != time.Second || merged.Retries != 5 || merged.Timeout != defaultHealthTimeout {
+		t.Errorf("merged %+v", merged)
+	}
+	if merged, _ := MergeHealthConfig(&HealthConfig{Test: []string{"NONE"}}, image); merged != nil {
+		t.Errorf("NONE should disable the image healthcheck")
+	}
+	if _, err := MergeHealthConfig(&HealthConfig{Test: []string{"BAD", "x"}}, nil); err == nil {
+		t.Errorf("invalid test should fail")
+	}
+}
+
+func TestHealthCommand(t *testing.T) {
+	shell := &HealthConfig{Test: []string{"CMD-SHELL", "test -f /ready || exit 1"}}
+	if cmd, err := shell.Command(); err != nil || cmd != "test -f /ready || exit 1" {
+		t.Errorf("CMD-SHELL command = %q, %v", cmd, err)
+	}
+	// exec form arguments reach the probe unchanged
+	probe := &HealthConfig{Test: []string{"CMD", "printf", "[%s]", "a  b", "it's", "$HOME", `"q"`, ";", ""}}
+	cmd, err := probe.Command()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("sh", "-c", cmd).Output()
+	if err != nil {
+		t.Fatalf("sh -c %s error: %v", cmd, err)
+	}
+	if want := `[a  b][it's][$HOME]["q"][;][]`; string(out) != want {
+		t.Errorf("sh -c %s printed %s, want %s", cmd, out, want)
+	}
+}
+
+func TestHealthRecord(t *testing.T) {
+	h := &Health{Status: HealthStarting}
+	h.Record(&HealthResult{ExitCode: 1}, false, 2)
+	if h.Status != HealthStarting || h.FailingStreak != 0 {
+		t.Errorf("failure in start period counted: %+v", h)
+	}
+	h.Record(&HealthResult{ExitCode: 1}, true, 2)
+	h.Record(&HealthResult{ExitCode: 1}, true, 2)
+	if h.Status != Unhealthy {
+		t.Errorf("status %s, want unhealthy", h.Status)
+	}
+	for i := 0; i < 10; i++ {
+		h.Record(&HealthResult{}, true, 2)
+	}
+	if h.Status != Healthy || h.FailingStreak != 0 || len(h.Log) != healthLogLength {
+		t.Errorf("health %+v", h)
+	}
+}
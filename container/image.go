@@ -0,0 +1,39 @@
+package container
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ImageConfig holds the runtime defaults of an image, in the shape of
+// the Config section of `docker inspect`
+type ImageConfig struct {
+	StopSignal  string            `json:"StopSignal"`
+	Healthcheck *HealthConfig     `json:"Healthcheck"`
+	WorkingDir  string            `json:"WorkingDir"`
+	User        string            `json:"User"`
+	Labels      map[string]string `json:"Labels"`
+}
+
+func imageConfigPath(imageName string) string {
+	return filepath.Join(RootDir, "images", imageName+".json")
+}
+
+// LoadImageConfig reads the config of an image, an image without one
+// gets an empty config
+func LoadImageConfig(imageName string) (*ImageConfig, error) {
+	config := &ImageConfig{}
+	content, err := ioutil.ReadFile(imageConfigPath(imageName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -1,9 +1,10 @@
 package container
 
 import (
+	"encoding/json"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
-	"io/ioutil"
+	"golang.org/x/sys/unix"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,48 +12,134 @@ import (
 	"syscall"
 )
 
+// RunContainerInitProcess sets up the container as its InitSpec says and
+// execs its command. Whatever fails is reported to the parent on the sync
+// pipe, which exec closes otherwise.
 func RunContainerInitProcess() error {
-	cmdArray := readUserCommand()
-	if cmdArray == nil || len(cmdArray) == 0 {
-		return fmt.Errorf("Run container get user command error, cmdArray is nil")
+	syncPipe := os.NewFile(uintptr(4), "sync")
+	syscall.CloseOnExec(int(syncPipe.Fd()))
+	err := initContainer()
+	if err != nil {
+		msg, _ := json.Marshal(&initError{Message: err.Error()})
+		syncPipe.Write(msg)
 	}
+	return err
+}
 
-	setUpMount()
-	path, err := exec.LookPath(cmdArray[0])
+func initContainer() error {
+	spec, err := readInitSpec()
 	if err != nil {
-		log.Errorf("Exec look path error %v", err)
 		return err
 	}
+	if len(spec.Args) == 0 {
+		return fmt.Errorf("Run container get user command error, cmdArray is nil")
+	}
+	if spec.Hostname != "" {
+		if err := syscall.Sethostname([]byte(spec.Hostname)); err != nil {
+			return fmt.Errorf("Set hostname error: %v", err)
+		}
+	}
+	if err := setUpMount(spec.ReadOnly, spec.Tmpfs); err != nil {
+		return fmt.Errorf("Set up mount error: %v", err)
+	}
+	if spec.Cwd != "" {
+		// created like docker does when the image lacks it
+		if err := os.MkdirAll(spec.Cwd, 0755); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("Mkdir workdir %s error: %v", spec.Cwd, err)
+		}
+		if err := os.Chdir(spec.Cwd); err != nil {
+			return fmt.Errorf("Chdir %s error: %v", spec.Cwd, err)
+		}
+	}
+	for _, r := range spec.Rlimits {
+		if err := unix.Setrlimit(rlimitResources[r.Name], &unix.Rlimit{Cur: r.Soft, Max: r.Hard}); err != nil {
+			return fmt.Errorf("Set ulimit %s error: %v", r.Name, err)
+		}
+	}
+	home := "/root"
+	if spec.User != "" {
+		if home, err = setUpUser(spec.User); err != nil {
+			return fmt.Errorf("Set up user error: %v", err)
+		}
+	}
+	env := spec.Env
+	if _, ok := lookupEnv(env, "HOME"); !ok {
+		env = append(env, "HOME="+home)
+	}
+	// the command is looked up in the PATH of the container
+	pathEnv, _ := lookupEnv(env, "PATH")
+	os.Setenv("PATH", pathEnv)
+	path, err := exec.LookPath(spec.Args[0])
+	if err != nil {
+		return fmt.Errorf("Exec look path error: %v", err)
+	}
 	log.Infof("Find path %s", path)
-	if err := syscall.Exec(path, cmdArray, os.Environ()); err != nil {
-		log.Errorf(err.Error())
+	if err := syscall.Exec(path, spec.Args, env); err != nil {
+		return fmt.Errorf("Exec %s error: %v", path, err)
 	}
 	return nil
 }
 
-func readUserCommand() []string {
-	pipe := os.NewFile(uintptr(3), "pipe")
-	msg, err := ioutil.ReadAll(pipe)
-	if err != nil {
-		log.Errorf("init read pipe error: %v", err)
-		return nil
+func readInitSpec() (*InitSpec, error) {
+	pipe := os.NewFile(uintptr(3), "spec")
+	defer pipe.Close()
+	var spec InitSpec
+	if err := json.NewDecoder(pipe).Decode(&spec); err != nil {
+		return nil, fmt.Errorf("Read init spec error: %v", err)
 	}
-	msgStr := string(msg)
-	return strings.Split(msgStr, " ")
+	if spec.Version != InitSpecVersion {
+		return nil, fmt.Errorf("Unsupported init spec version %d, expected %d", spec.Version, InitSpecVersion)
+	}
+	return &spec, nil
 }
 
-func setUpMount() {
+func setUpMount(readOnly bool, tmpfs []string) error {
 	pwd, err := os.Getwd()
 	if err != nil {
-		log.Errorf("Get current location error: %v", err)
+		return fmt.Errorf("Get current location error: %v", err)
 	}
 	log.Infof("Current location is %s", pwd)
-	pivotRoot(pwd)
+	if err := pivotRoot(pwd); err != nil {
+		return err
+	}
 	//mount proc
 	defaultMountFlags := syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NODEV
 	syscall.Mount("proc", "/proc", "proc", uintptr(defaultMountFlags), "")
 	// mount dev
 	syscall.Mount("tmpfs", "/dev", "tmpfs", syscall.MS_NOSUID|syscall.MS_STRICTATIME, "mode=755")
+	for _, t := range tmpfs {
+		if err := mountTmpfs(t); err != nil {
+			return err
+		}
+	}
+	if readOnly {
+		// only the root mount itself turns read-only,
+		// volumes and tmpfs mounted on top of it stay writable
+		if err := syscall.Mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("Remount rootfs read-only error: %v", err)
+		}
+	}
+	return nil
+}
+
+// mountTmpfs mounts a tmpfs described as "path[:options]", e.g. "/run:size=64m"
+func mountTmpfs(tmpfs string) error {
+	parts := strings.SplitN(tmpfs, ":", 2)
+	target := parts[0]
+	options := ""
+	if len(parts) == 2 {
+		options = parts[1]
+	}
+	if !filepath.IsAbs(target) {
+		return fmt.Errorf("Tmpfs path %s is not absolute", target)
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("Mkdir tmpfs mount point %s error: %v", target, err)
+	}
+	if err := syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, options); err != nil {
+		return fmt.Errorf("Mount tmpfs on %s error: %v", target, err)
+	}
+	return nil
 }
 
 func pivotRoot(newRoot string) error {
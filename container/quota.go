@@ -0,0 +1,317 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	xfsSuperMagic = 0x58465342
+	// project ids handed out to write layers start here
+	projectIdBase = 100000
+	// held while a project id is picked and recorded
+	projectIdLockName = "projid.lock"
+)
+
+// quotaDir keeps loop images and project ids of size limited write layers
+func quotaDir() string {
+	return filepath.Join(RootDir, "quota")
+}
+
+func quotaImagePath(containerName string) string {
+	return filepath.Join(quotaDir(), containerName+".img")
+}
+
+func quotaProjectIdPath(containerName string) string {
+	return filepath.Join(quotaDir(), containerName+".projid")
+}
+
+// ParseStorageOpt checks --storage-opt key=value pairs, only size is supported
+func ParseStorageOpt(opts []string) (map[string]string, error) {
+	storageOpt := map[string]string{}
+	for _, opt := range opts {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid storage option: %s", opt)
+		}
+		key := strings.ToLower(kv[0])
+		if key != "size" {
+			return nil, fmt.Errorf("Unknown storage option: %s", kv[0])
+		}
+		if _, err := ParseSize(kv[1]); err != nil {
+			return nil, err
+		}
+		storageOpt[key] = kv[1]
+	}
+	return storageOpt, nil
+}
+
+// ParseSize parses sizes like 512k, 10M or 1G into bytes
+func ParseSize(size string) (int64, error) {
+	s := strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(size), "b"), "i")
+	multiplier := int64(1)
+	if len(s) > 0 {
+		if i := strings.IndexByte("kmgt", s[len(s)-1]); i != -1 {
+			multiplier = int64(1) << (10 * uint(i+1))
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("Invalid size: %s", size)
+	}
+	return n * multiplier, nil
+}
+
+// CreateQuotaLayer limits the size of everything the container writes under
+// its layer dir. XFS project quotas are used when the backing filesystem
+// supports them, otherwise a size limited ext4 image is loop mounted on it.
+func CreateQuotaLayer(containerName, size string) error {
+	bytes, err := ParseSize(size)
+	if err != nil {
+		return err
+	}
+	containerLayerPath := layerPath(containerName)
+	if err := os.MkdirAll(containerLayerPath, 0777); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(quotaDir(), 0700); err != nil {
+		return err
+	}
+	if mountPoint, ok := projectQuotaMountPoint(containerLayerPath); ok {
+		return setProjectQuota(containerName, mountPoint, bytes)
+	}
+	return mountQuotaImage(containerName, bytes)
+}
+
+// DeleteQuotaLayer undoes CreateQuotaLayer, it must be called after the
+// overlay has been unmounted
+func DeleteQuotaLayer(containerName string) error {
+	containerLayerPath := layerPath(containerName)
+	if err := unmountQuotaLayer(containerName); err != nil {
+		return err
+	}
+	if err := os.Remove(quotaImagePath(containerName)); err != nil && !os.IsNotExist(err) {
+		log.Errorf("Remove quota image error: %v", err)
+	}
+	if content, err := ioutil.ReadFile(quotaProjectIdPath(containerName)); err == nil {
+		if mountPoint, ok := projectQuotaMountPoint(filepath.Dir(containerLayerPath)); ok {
+			clear := fmt.Sprintf("limit -p bhard=0 %s", strings.TrimSpace(string(content)))
+			if output, err := exec.Command("xfs_quota", "-x", "-c", clear, mountPoint).CombinedOutput(); err != nil {
+				log.Errorf("Clear project quota error: %v %s", err, output)
+			}
+		}
+		os.Remove(quotaProjectIdPath(containerName))
+	}
+	return nil
+}
+
+// remountQuotaLayer mounts the image of a size limited write layer on the
+// layer dir again, loop mounts do not survive a reboot
+func remountQuotaLayer(containerName string) error {
+	imagePath := quotaImagePath(containerName)
+	if exists, _ := PathExists(imagePath); !exists {
+		return nil
+	}
+	containerLayerPath := layerPath(containerName)
+	if mounted, _ := loopMounted(containerLayerPath); mounted {
+		return nil
+	}
+	if err := privateLayerDir(containerName); err != nil {
+		return err
+	}
+	if output, err := exec.Command("mount", "-o", "loop", imagePath, containerLayerPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("Mount quota image error: %v %s", err, output)
+	}
+	return nil
+}
+
+// privateLayerDir binds the layer dir of a container on itself and makes
+// that bind private before a quota image is mounted on it. A mount whose
+// parent mount is shared, as systemd makes "/", cannot be moved, and
+// renaming moves quota layers. Only the container's own dir is touched,
+// the propagation of the other host mounts stays as it is.
+func privateLayerDir(containerName string) error {
+	dir := layerPath(containerName)
+	mounted, err := IsMounted(dir)
+	if err != nil {
+		return err
+	}
+	if !mounted {
+		if output, err := exec.Command("mount", "--bind", dir, dir).CombinedOutput(); err != nil {
+			return fmt.Errorf("Bind mount %s error: %v %s", dir, err, output)
+		}
+	}
+	if output, err := exec.Command("mount", "--make-private", dir).CombinedOutput(); err != nil {
+		return fmt.Errorf("Make %s private error: %v %s", dir, err, output)
+	}
+	return nil
+}
+
+// loopMounted tells whether a loop device, so a quota image, is mounted on path
+func loopMounted(path string) (bool, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	path = filepath.Clean(path)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), " ")
+		// loop devices have major number 7
+		if len(fields) > 4 && fields[4] == path && strings.HasPrefix(fields[2], "7:") {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// unmountQuotaLayer unmounts the quota image and the private bind under it
+func unmountQuotaLayer(containerName string) error {
+	containerLayerPath := layerPath(containerName)
+	for {
+		mounted, err := IsMounted(containerLayerPath)
+		if err != nil {
+			return err
+		}
+		if !mounted {
+			return nil
+		}
+		if output, err := exec.Command("umount", containerLayerPath).CombinedOutput(); err != nil {
+			return fmt.Errorf("Umount quota layer %s error: %v %s", containerLayerPath, err, output)
+		}
+	}
+}
+
+func mountQuotaImage(containerName string, bytes int64) error {
+	imagePath := quotaImagePath(containerName)
+	image, err := os.OpenFile(imagePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Create quota image %s error: %v", imagePath, err)
+	}
+	// sparse, only what is written takes space
+	err = image.Truncate(bytes)
+	image.Close()
+	if err != nil {
+		os.Remove(imagePath)
+		return err
+	}
+	if output, err := exec.Command("mkfs.ext4", "-q", "-F", "-m", "0", imagePath).CombinedOutput(); err != nil {
+		os.Remove(imagePath)
+		return fmt.Errorf("mkfs quota image error: %v %s", err, output)
+	}
+	if err := privateLayerDir(containerName); err != nil {
+		os.Remove(imagePath)
+		return err
+	}
+	containerLayerPath := layerPath(containerName)
+	if output, err := exec.Command("mount", "-o", "loop", imagePath, containerLayerPath).CombinedOutput(); err != nil {
+		unmountQuotaLayer(containerName)
+		os.Remove(imagePath)
+		return fmt.Errorf("Mount quota image error: %v %s", err, output)
+	}
+	// the fresh filesystem only holds lost+found
+	os.RemoveAll(filepath.Join(containerLayerPath, "lost+found"))
+	return nil
+}
+
+func setProjectQuota(containerName, mountPoint string, bytes int64) error {
+	projectId, err := allocateProjectId(containerName)
+	if err != nil {
+		return err
+	}
+	setup := fmt.Sprintf("project -s -p %s %d", layerPath(containerName), projectId)
+	limit := fmt.Sprintf("limit -p bhard=%d %d", bytes, projectId)
+	if output, err := exec.Command("xfs_quota", "-x", "-c", setup, "-c", limit, mountPoint).CombinedOutput(); err != nil {
+		os.Remove(quotaProjectIdPath(containerName))
+		return fmt.Errorf("Set project quota error: %v %s", err, output)
+	}
+	return nil
+}
+
+// allocateProjectId records an unused project id for the container, under
+// a lock so that concurrent creates do not share an id and so a quota
+func allocateProjectId(containerName string) (int, error) {
+	lock, err := os.OpenFile(filepath.Join(quotaDir(), projectIdLockName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return 0, err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	projectId, err := nextProjectId()
+	if err != nil {
+		return 0, err
+	}
+	if err := ioutil.WriteFile(quotaProjectIdPath(containerName), []byte(strconv.Itoa(projectId)), 0600); err != nil {
+		return 0, err
+	}
+	return projectId, nil
+}
+
+func nextProjectId() (int, error) {
+	files, err := filepath.Glob(filepath.Join(quotaDir(), "*.projid"))
+	if err != nil {
+		return 0, err
+	}
+	next := projectIdBase
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimSpace(string(content))); err == nil && id >= next {
+			next = id + 1
+		}
+	}
+	return next, nil
+}
+
+// projectQuotaMountPoint returns the mount point of path when it lives
+// on XFS mounted with project quotas
+func projectQuotaMountPoint(path string) (string, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil || stat.Type != xfsSuperMagic {
+		return "", false
+	}
+	if _, err := exec.LookPath("xfs_quota"); err != nil {
+		return "", false
+	}
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	mountPoint, superOpts := "", ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), " ")
+		if len(fields) < 5 {
+			continue
+		}
+		mp := fields[4]
+		if (path == mp || strings.HasPrefix(path, strings.TrimSuffix(mp, "/")+"/")) && len(mp) >= len(mountPoint) {
+			mountPoint, superOpts = mp, fields[len(fields)-1]
+		}
+	}
+	for _, opt := range strings.Split(superOpts, ",") {
+		if opt == "prjquota" || opt == "pquota" {
+			return mountPoint, true
+		}
+	}
+	return "", false
+}
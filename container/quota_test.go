@@ -0,0 +1,104 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"1":     1,
+		"512k":  512 << 10,
+		"512K":  512 << 10,
+		"10m":   10 << 20,
+		"10MB":  10 << 20,
+		"10MiB": 10 << 20,
+		"1g":    1 << 30,
+		"2T":    2 << 40,
+		"100b":  100,
+	}
+	for in, want := range cases {
+		got, err := ParseSize(in)
+		if err != nil {
+			t.Errorf("ParseSize(%q) error: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseSize(%q) = %d, want %d", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "k", "0", "-1m", "1.5g", "10x", "ten"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) should fail", in)
+		}
+	}
+}
+
+func TestParseStorageOpt(t *testing.T) {
+	cases := []struct {
+		opts []string
+		want map[string]string
+	}{
+		{nil, map[string]string{}},
+		{[]string{"size=10G"}, map[string]string{"size": "10G"}},
+		{[]string{"SIZE=512m"}, map[string]string{"size": "512m"}},
+		{[]string{"size=1g", "size=2g"}, map[string]string{"size": "2g"}},
+	}
+	for _, c := range cases {
+		got, err := ParseStorageOpt(c.opts)
+		if err != nil {
+			t.Errorf("ParseStorageOpt(%q) error: %v", c.opts, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseStorageOpt(%q) = %v, want %v", c.opts, got, c.want)
+		}
+	}
+	for _, opts := range [][]string{{"size"}, {"size=big"}, {"size=0"}, {"inodes=100"}, {"size=1g", "foo=bar"}} {
+		if _, err := ParseStorageOpt(opts); err == nil {
+			t.Errorf("ParseStorageOpt(%q) should fail", opts)
+		}
+	}
+}
+
+func TestAllocateProjectId(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mydocker-quota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetRootDir(RootDir)
+	SetRootDir(dir)
+	if err := os.MkdirAll(quotaDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent creates must not be handed the same id
+	const n = 16
+	ids := make([]int, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], errs[i] = allocateProjectId(fmt.Sprintf("c%d", i))
+		}(i)
+	}
+	wg.Wait()
+	seen := map[int]bool{}
+	for i, id := range ids {
+		if errs[i] != nil {
+			t.Fatalf("allocateProjectId error: %v", errs[i])
+		}
+		if id < projectIdBase || id >= projectIdBase+n || seen[id] {
+			t.Errorf("project ids = %v, want distinct ids from %d", ids, projectIdBase)
+			break
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,95 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RestartNo            = "no"
+	RestartAlways        = "always"
+	RestartUnlessStopped = "unless-stopped"
+	RestartOnFailure     = "on-failure"
+
+	restartBackoffMin = 100 * time.Millisecond
+	restartBackoffMax = time.Minute
+	// a container running longer than this is no longer crash looping
+	restartBackoffReset = 10 * time.Second
+)
+
+type RestartPolicy struct {
+	Name string `json:"name"`
+	// on-failure only, 0 retries forever
+	MaximumRetryCount int `json:"maximumRetryCount"`
+}
+
+// ParseRestartPolicy parses no, always, unless-stopped or on-failure[:N]
+func ParseRestartPolicy(policy string) (*RestartPolicy, error) {
+	if policy == "" {
+		return &RestartPolicy{Name: RestartNo}, nil
+	}
+	parts := strings.SplitN(policy, ":", 2)
+	p := &RestartPolicy{Name: parts[0]}
+	switch p.Name {
+	case RestartNo, RestartAlways, RestartUnlessStopped:
+		if len(parts) == 2 {
+			return nil, fmt.Errorf("Maximum retry count cannot be used with restart policy %s", p.Name)
+		}
+	case RestartOnFailure:
+		if len(parts) == 2 {
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("Invalid maximum retry count: %s", parts[1])
+			}
+			p.MaximumRetryCount = n
+		}
+	default:
+		return nil, fmt.Errorf("Invalid restart policy: %s", policy)
+	}
+	return p, nil
+}
+
+func (p *RestartPolicy) String() string {
+	if p.Name == RestartOnFailure && p.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", p.Name, p.MaximumRetryCount)
+	}
+	return p.Name
+}
+
+// ShouldRestart tells whether the monitor restarts a container which
+// exited on its own
+func (p *RestartPolicy) ShouldRestart(exitCode, restartCount int, manuallyStopped bool) bool {
+	if p == nil || manuallyStopped {
+		return false
+	}
+	switch p.Name {
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0 && (p.MaximumRetryCount == 0 || restartCount < p.MaximumRetryCount)
+	}
+	return false
+}
+
+// RestartOnBoot tells whether a container is brought back after mydocker
+// or the host restarted, always even brings back stopped containers
+func (p *RestartPolicy) RestartOnBoot(manuallyStopped bool) bool {
+	if p == nil {
+		return false
+	}
+	return p.Name == RestartAlways || (p.Name == RestartUnlessStopped && !manuallyStopped)
+}
+
+// RestartBackoff doubles the delay before each restart of a crash looping
+// container, a container which ran long enough starts over
+func RestartBackoff(previous, ran time.Duration) time.Duration {
+	if previous == 0 || ran >= restartBackoffReset {
+		return restartBackoffMin
+	}
+	if previous*2 > restartBackoffMax {
+		return restartBackoffMax
+	}
+	return previous * 2
+}
@@ -0,0 +1,62 @@
+package container
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	cases := map[string]RestartPolicy{
+		"":               {Name: RestartNo},
+		"no":             {Name: RestartNo},
+		"always":         {Name: RestartAlways},
+		"unless-stopped": {Name: RestartUnlessStopped},
+		"on-failure":     {Name: RestartOnFailure},
+		"on-failure:3":   {Name: RestartOnFailure, MaximumRetryCount: 3},
+	}
+	for in, want := range cases {
+		p, err := ParseRestartPolicy(in)
+		if err != nil {
+			t.Errorf("ParseRestartPolicy(%q) error: %v", in, err)
+			continue
+		}
+		if *p != want {
+			t.Errorf("ParseRestartPolicy(%q) = %+v, want %+v", in, *p, want)
+		}
+	}
+	for _, in := range []string{"sometimes", "always:3", "on-failure:x", "on-failure:-1"} {
+		if _, err := ParseRestartPolicy(in); err == nil {
+			t.Errorf("ParseRestartPolicy(%q) should fail", in)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	onFailure := &RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 2}
+	if !onFailure.ShouldRestart(1, 1, false) || onFailure.ShouldRestart(1, 2, false) || onFailure.ShouldRestart(0, 0, false) {
+		t.Errorf("on-failure:2 restarts wrongly")
+	}
+	always := &RestartPolicy{Name: RestartAlways}
+	if !always.ShouldRestart(0, 100, false) || always.ShouldRestart(1, 0, true) {
+		t.Errorf("always restarts wrongly")
+	}
+	if !always.RestartOnBoot(true) || (&RestartPolicy{Name: RestartUnlessStopped}).RestartOnBoot(true) {
+		t.Errorf("restart on boot wrongly")
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	d := RestartBackoff(0, 0)
+	if d != restartBackoffMin {
+		t.Errorf("first backoff %v", d)
+	}
+	for i := 0; i < 20; i++ {
+		d = RestartBackoff(d, time.Second)
+	}
+	if d != restartBackoffMax {
+		t.Errorf("backoff not capped: %v", d)
+	}
+	if RestartBackoff(d, time.Minute) != restartBackoffMin {
+		t.Errorf("backoff not reset")
+	}
+}
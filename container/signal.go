@@ -0,0 +1,125 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var signalNames = map[string]syscall.Signal{
+	"ABRT":   syscall.SIGABRT,
+	"ALRM":   syscall.SIGALRM,
+	"BUS":    syscall.SIGBUS,
+	"CHLD":   syscall.SIGCHLD,
+	"CONT":   syscall.SIGCONT,
+	"FPE":    syscall.SIGFPE,
+	"HUP":    syscall.SIGHUP,
+	"ILL":    syscall.SIGILL,
+	"INT":    syscall.SIGINT,
+	"IO":     syscall.SIGIO,
+	"KILL":   syscall.SIGKILL,
+	"PIPE":   syscall.SIGPIPE,
+	"PROF":   syscall.SIGPROF,
+	"PWR":    syscall.SIGPWR,
+	"QUIT":   syscall.SIGQUIT,
+	"SEGV":   syscall.SIGSEGV,
+	"STOP":   syscall.SIGSTOP,
+	"SYS":    syscall.SIGSYS,
+	"TERM":   syscall.SIGTERM,
+	"TRAP":   syscall.SIGTRAP,
+	"TSTP":   syscall.SIGTSTP,
+	"TTIN":   syscall.SIGTTIN,
+	"TTOU":   syscall.SIGTTOU,
+	"URG":    syscall.SIGURG,
+	"USR1":   syscall.SIGUSR1,
+	"USR2":   syscall.SIGUSR2,
+	"VTALRM": syscall.SIGVTALRM,
+	"WINCH":  syscall.SIGWINCH,
+	"XCPU":   syscall.SIGXCPU,
+	"XFSZ":   syscall.SIGXFSZ,
+}
+
+// ParseSignal accepts a signal as a number or a name with or without
+// the SIG prefix, e.g. 15, TERM or SIGTERM
+func ParseSignal(signal string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(signal); err == nil {
+		if n <= 0 || n > 64 {
+			return 0, fmt.Errorf("Invalid signal: %s", signal)
+		}
+		return syscall.Signal(n), nil
+	}
+	sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(signal), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("Invalid signal: %s", signal)
+	}
+	return sig, nil
+}
+
+// IsProcessAlive tells whether pid is running, a zombie waiting to be
+// reaped has already gone
+func IsProcessAlive(pid int) bool {
+	if pid <= 0 || syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	state := processState(pid)
+	return state != "" && state != "Z" && state != "X"
+}
+
+// WaitProcessExit waits up to timeout for pid to go away
+func WaitProcessExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for IsProcessAlive(pid) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
+// IsProcessInstanceAlive tells whether the process started at startTime
+// is still alive, pid may belong to an unrelated process by now. A zero
+// startTime only checks pid.
+func IsProcessInstanceAlive(pid int, startTime uint64) bool {
+	if !IsProcessAlive(pid) {
+		return false
+	}
+	if startTime == 0 {
+		return true
+	}
+	t, err := ProcessStartTime(pid)
+	return err == nil && t == startTime
+}
+
+// ProcessStartTime returns when pid started, in clock ticks since boot
+func ProcessStartTime(pid int) (uint64, error) {
+	fields := processStat(pid)
+	// starttime is the 22nd field, the 20th after the command name
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("Parse /proc/%d/stat error", pid)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// processState returns the state letter of /proc/<pid>/stat, R, S, T...
+func processState(pid int) string {
+	fields := processStat(pid)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// processStat returns the fields of /proc/<pid>/stat after the command name
+func processStat(pid int) []string {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil
+	}
+	// the command name in parentheses may contain spaces
+	stat := string(content)
+	return strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+}
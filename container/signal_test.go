@@ -0,0 +1,24 @@
+package container
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsProcessInstanceAlive(t *testing.T) {
+	pid := os.Getpid()
+	startTime, err := ProcessStartTime(pid)
+	if err != nil || startTime == 0 {
+		t.Fatalf("ProcessStartTime(%d) = %d, %v", pid, startTime, err)
+	}
+	if !IsProcessInstanceAlive(pid, startTime) {
+		t.Errorf("IsProcessInstanceAlive(%d, %d) = false, want true", pid, startTime)
+	}
+	if !IsProcessInstanceAlive(pid, 0) {
+		t.Errorf("IsProcessInstanceAlive(%d, 0) = false, want true", pid)
+	}
+	// the pid reused by a later process
+	if IsProcessInstanceAlive(pid, startTime-1) {
+		t.Errorf("IsProcessInstanceAlive(%d, %d) = true, want false", pid, startTime-1)
+	}
+}
@@ -0,0 +1,208 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InitSpecVersion changes whenever InitSpec does incompatibly
+const InitSpecVersion = 1
+
+// defaultPathEnv is the PATH of a container whose image and -e set none
+const defaultPathEnv = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// InitSpec tells the init process of a container what to set up and run,
+// it is sent as JSON over the spec pipe. The rootfs and volumes are mounted
+// by the parent before init runs, tmpfs is the only mount init makes.
+type InitSpec struct {
+	Version  int       `json:"version"`
+	Args     []string  `json:"args"`
+	Env      []string  `json:"env"`
+	Cwd      string    `json:"cwd,omitempty"`
+	User     string    `json:"user,omitempty"`
+	Hostname string    `json:"hostname,omitempty"`
+	ReadOnly bool      `json:"readOnly,omitempty"`
+	Tmpfs    []string  `json:"tmpfs,omitempty"`
+	Rlimits  []*Rlimit `json:"rlimits,omitempty"`
+}
+
+// Rlimit is a resource limit of the container processes, as `--ulimit`
+type Rlimit struct {
+	Name string
+	Soft uint64
+	Hard uint64
+}
+
+var rlimitResources = map[string]int{
+	"as":      unix.RLIMIT_AS,
+	"core":    unix.RLIMIT_CORE,
+	"cpu":     unix.RLIMIT_CPU,
+	"data":    unix.RLIMIT_DATA,
+	"fsize":   unix.RLIMIT_FSIZE,
+	"memlock": unix.RLIMIT_MEMLOCK,
+	"nofile":  unix.RLIMIT_NOFILE,
+	"nproc":   unix.RLIMIT_NPROC,
+	"stack":   unix.RLIMIT_STACK,
+}
+
+// ParseUlimit parses "name=soft[:hard]", e.g. "nofile=1024:2048"
+func ParseUlimit(ulimit string) (*Rlimit, error) {
+	parts := strings.SplitN(ulimit, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid ulimit %s, should be name=soft[:hard]", ulimit)
+	}
+	if _, ok := rlimitResources[parts[0]]; !ok {
+		return nil, fmt.Errorf("Invalid ulimit type: %s", parts[0])
+	}
+	limits := strings.SplitN(parts[1], ":", 2)
+	soft, err := strconv.ParseUint(limits[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ulimit %s: %v", ulimit, err)
+	}
+	hard := soft
+	if len(limits) == 2 {
+		if hard, err = strconv.ParseUint(limits[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid ulimit %s: %v", ulimit, err)
+		}
+	}
+	if soft > hard {
+		return nil, fmt.Errorf("Invalid ulimit %s, soft limit is above hard limit", ulimit)
+	}
+	return &Rlimit{Name: parts[0], Soft: soft, Hard: hard}, nil
+}
+
+// NewInitSpec describes the process of a container for its init
+func NewInitSpec(containerInfo *ContainerInfo) *InitSpec {
+	spec := &InitSpec{
+		Version:  InitSpecVersion,
+		Args:     containerInfo.Args,
+		Cwd:      containerInfo.WorkingDir,
+		User:     containerInfo.User,
+		ReadOnly: containerInfo.ReadOnly,
+		Tmpfs:    containerInfo.Tmpfs,
+		Rlimits:  containerInfo.Ulimits,
+	}
+	if len(containerInfo.Id) >= 12 {
+		spec.Hostname = containerInfo.Id[:12]
+	}
+	// a fixed base overridden by the image and -e values, the environment
+	// of the process launching the container stays out
+	env := []string{defaultPathEnv}
+	if spec.Hostname != "" {
+		env = append(env, "HOSTNAME="+spec.Hostname)
+	}
+	if containerInfo.Tty {
+		env = append(env, "TERM=xterm")
+	}
+	spec.Env = mergeEnv(env, containerInfo.Env)
+	return spec
+}
+
+// mergeEnv sets the KEY=value entries of overrides in env, replacing the
+// entries of the same keys
+func mergeEnv(env, overrides []string) []string {
+	result := append([]string{}, env...)
+	for _, kv := range overrides {
+		key := strings.SplitN(kv, "=", 2)[0]
+		replaced := false
+		for i, e := range result {
+			if strings.SplitN(e, "=", 2)[0] == key {
+				result[i] = kv
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, kv)
+		}
+	}
+	return result
+}
+
+// lookupEnv returns the value of key in the KEY=value entries of env
+func lookupEnv(env []string, key string) (string, bool) {
+	for _, kv := range env {
+		if parts := strings.SplitN(kv, "=", 2); parts[0] == key {
+			if len(parts) == 1 {
+				return "", true
+			}
+			return parts[1], true
+		}
+	}
+	return "", false
+}
+
+// InitPipe holds the pipes between a container init process and its
+// parent. The spec pipe carries the InitSpec, init writes an initError on
+// the sync pipe when it fails, or closes it by exec'ing the command.
+type InitPipe struct {
+	specWriter *os.File
+	syncReader *os.File
+	// the ends of the init process, fds 3 and 4
+	childFiles []*os.File
+}
+
+type initError struct {
+	Message string `json:"message"`
+}
+
+func newInitPipe() (*InitPipe, error) {
+	specReader, specWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	syncReader, syncWriter, err := os.Pipe()
+	if err != nil {
+		specReader.Close()
+		specWriter.Close()
+		return nil, err
+	}
+	return &InitPipe{
+		specWriter: specWriter,
+		syncReader: syncReader,
+		childFiles: []*os.File{specReader, syncWriter},
+	}, nil
+}
+
+// CloseChildFiles closes the ends given to the init process once it has
+// started, the sync pipe only reaches EOF after that
+func (p *InitPipe) CloseChildFiles() {
+	for _, f := range p.childFiles {
+		f.Close()
+	}
+	p.childFiles = nil
+}
+
+func (p *InitPipe) Close() {
+	p.CloseChildFiles()
+	p.specWriter.Close()
+	p.syncReader.Close()
+}
+
+// Send sends spec to the init process and waits until it has exec'd the
+// container command, the error init reports otherwise is returned
+func (p *InitPipe) Send(spec *InitSpec) error {
+	defer p.syncReader.Close()
+	err := json.NewEncoder(p.specWriter).Encode(spec)
+	p.specWriter.Close()
+	if err != nil {
+		return fmt.Errorf("Send init spec error: %v", err)
+	}
+	msg, err := ioutil.ReadAll(p.syncReader)
+	if err != nil {
+		return fmt.Errorf("Read init sync pipe error: %v", err)
+	}
+	if len(msg) == 0 {
+		return nil
+	}
+	var initErr initError
+	if err := json.Unmarshal(msg, &initErr); err != nil {
+		return fmt.Errorf("Invalid message from init: %s", msg)
+	}
+	return fmt.Errorf("%s", initErr.Message)
+}
@@ -0,0 +1,118 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseUlimit(t *testing.T) {
+	cases := map[string]Rlimit{
+		"nofile=1024":      {Name: "nofile", Soft: 1024, Hard: 1024},
+		"nofile=1024:2048": {Name: "nofile", Soft: 1024, Hard: 2048},
+		"core=0":           {Name: "core", Soft: 0, Hard: 0},
+	}
+	for in, want := range cases {
+		r, err := ParseUlimit(in)
+		if err != nil {
+			t.Errorf("ParseUlimit(%q) error: %v", in, err)
+			continue
+		}
+		if *r != want {
+			t.Errorf("ParseUlimit(%q) = %+v, want %+v", in, *r, want)
+		}
+	}
+	for _, in := range []string{"nofile", "files=10", "nofile=x", "nofile=10:5", "nofile=1:2:3"} {
+		if _, err := ParseUlimit(in); err == nil {
+			t.Errorf("ParseUlimit(%q) should fail", in)
+		}
+	}
+}
+
+// TestInitPipeHelper is the init process of TestInitPipe. It reads the
+// spec like init does and echoes the args back as an init error.
+func TestInitPipeHelper(t *testing.T) {
+	mode := os.Getenv("MYDOCKER_INIT_PIPE_HELPER")
+	if mode == "" {
+		return
+	}
+	syncPipe := os.NewFile(uintptr(4), "sync")
+	spec, err := readInitSpec()
+	if err == nil && mode == "echo" {
+		args, _ := json.Marshal(spec.Args)
+		err = fmt.Errorf("%s", args)
+	}
+	if err != nil {
+		msg, _ := json.Marshal(&initError{Message: err.Error()})
+		syncPipe.Write(msg)
+	}
+	os.Exit(0)
+}
+
+func sendToInitHelper(t *testing.T, mode string, spec *InitSpec) error {
+	p, err := newInitPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=TestInitPipeHelper")
+	cmd.Env = append(os.Environ(), "MYDOCKER_INIT_PIPE_HELPER="+mode)
+	cmd.ExtraFiles = p.childFiles
+	if err := cmd.Start(); err != nil {
+		p.Close()
+		t.Fatal(err)
+	}
+	p.CloseChildFiles()
+	err = p.Send(spec)
+	if waitErr := cmd.Wait(); waitErr != nil {
+		t.Fatalf("init helper error: %v", waitErr)
+	}
+	// Send leaves no end of the pipes open
+	if p.syncReader.Fd() != ^uintptr(0) || p.specWriter.Fd() != ^uintptr(0) {
+		t.Error("Send left the init pipes open")
+	}
+	return err
+}
+
+func TestInitPipe(t *testing.T) {
+	args := []string{"sh", "-c", `echo "a  b" 'c'`, "with space", `quote"d`, `back\\slash`, ""}
+	err := sendToInitHelper(t, "echo", &InitSpec{Version: InitSpecVersion, Args: args})
+	if err == nil {
+		t.Fatal("expected the init error echoing the args")
+	}
+	var got []string
+	if err := json.Unmarshal([]byte(err.Error()), &got); err != nil {
+		t.Fatalf("unexpected init error %q", err)
+	}
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("init got args %q, want %q", got, args)
+	}
+
+	if err := sendToInitHelper(t, "ok", &InitSpec{Version: InitSpecVersion, Args: args}); err != nil {
+		t.Errorf("Send error when init succeeds: %v", err)
+	}
+
+	err = sendToInitHelper(t, "ok", &InitSpec{Version: InitSpecVersion + 1, Args: args})
+	if err == nil || !strings.Contains(err.Error(), "Unsupported init spec version") {
+		t.Errorf("Send error for a wrong version = %v", err)
+	}
+}
+
+func TestNewInitSpecEnv(t *testing.T) {
+	os.Setenv("MYDOCKER_TEST_LEAK", "1")
+	defer os.Unsetenv("MYDOCKER_TEST_LEAK")
+	info := &ContainerInfo{Id: "0123456789abcdef", Env: []string{"FOO=bar", "PATH=/app/bin"}}
+	want := []string{"PATH=/app/bin", "HOSTNAME=0123456789ab", "FOO=bar"}
+	if env := NewInitSpec(info).Env; !reflect.DeepEqual(env, want) {
+		t.Errorf("Env = %q, want %q", env, want)
+	}
+
+	info = &ContainerInfo{Id: "0123456789abcdef", Tty: true, Env: []string{"TERM=vt100", "FOO=1", "FOO=2"}}
+	want = []string{defaultPathEnv, "HOSTNAME=0123456789ab", "TERM=vt100", "FOO=2"}
+	if env := NewInitSpec(info).Env; !reflect.DeepEqual(env, want) {
+		t.Errorf("tty Env = %q, want %q", env, want)
+	}
+}
@@ -0,0 +1,123 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setUpUser switches the init process to user, "user[:group]" given as
+// names or ids, looked up in the container's /etc/passwd and /etc/group.
+// The home dir of the user is returned.
+func setUpUser(user string) (string, error) {
+	u, err := lookupUser(user, "/etc/passwd", "/etc/group")
+	if err != nil {
+		return "", err
+	}
+	if err := syscall.Setgroups(u.groups); err != nil {
+		return "", fmt.Errorf("Setgroups error: %v", err)
+	}
+	if err := syscall.Setgid(u.gid); err != nil {
+		return "", fmt.Errorf("Setgid %d error: %v", u.gid, err)
+	}
+	if err := syscall.Setuid(u.uid); err != nil {
+		return "", fmt.Errorf("Setuid %d error: %v", u.uid, err)
+	}
+	return u.home, nil
+}
+
+// execUser is the identity a user spec resolves to
+type execUser struct {
+	uid, gid int
+	// supplementary groups, gid first
+	groups []int
+	home   string
+}
+
+// lookupUser resolves "user[:group]" in the given passwd and group files,
+// missing files only allow numeric ids
+func lookupUser(user, passwdPath, groupPath string) (*execUser, error) {
+	name, group := user, ""
+	if i := strings.Index(user, ":"); i != -1 {
+		name, group = user[:i], user[i+1:]
+	}
+	uid, gid, home := -1, -1, "/"
+	// an unknown numeric id is used as it is
+	if id, err := strconv.Atoi(name); err == nil {
+		uid, gid = id, id
+	}
+	entries, err := readColonFile(passwdPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if len(e) < 6 || (e[0] != name && e[2] != name) {
+			continue
+		}
+		uid, _ = strconv.Atoi(e[2])
+		gid, _ = strconv.Atoi(e[3])
+		home = e[5]
+		// group members are listed by name
+		name = e[0]
+		break
+	}
+	if uid == -1 {
+		return nil, fmt.Errorf("Unable to find user %s", user)
+	}
+
+	groups, err := readColonFile(groupPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if group != "" {
+		gid = -1
+		if id, err := strconv.Atoi(group); err == nil {
+			gid = id
+		}
+		for _, e := range groups {
+			if len(e) >= 3 && (e[0] == group || e[2] == group) {
+				gid, _ = strconv.Atoi(e[2])
+				break
+			}
+		}
+		if gid == -1 {
+			return nil, fmt.Errorf("Unable to find group %s", group)
+		}
+	}
+	// supplementary groups listing the user by name
+	supplementary := []int{gid}
+	for _, e := range groups {
+		if len(e) < 4 {
+			continue
+		}
+		for _, member := range strings.Split(e[3], ",") {
+			if member == name {
+				if id, err := strconv.Atoi(e[2]); err == nil && id != gid {
+					supplementary = append(supplementary, id)
+				}
+			}
+		}
+	}
+	return &execUser{uid: uid, gid: gid, groups: supplementary, home: home}, nil
+}
+
+func readColonFile(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, strings.Split(line, ":"))
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,73 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testPasswd = `# comment
+root:x:0:0:root:/root:/bin/sh
+
+app:x:1000:1000:app user:/home/app:/bin/sh
+daemon:x:2:2::/sbin:/sbin/nologin
+broken:x:3
+`
+
+const testGroup = `root:x:0:
+app:x:1000:
+wheel:x:10:app,daemon
+docker:x:999:daemon,app
+audio:x:63:
+`
+
+func TestLookupUser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mydocker-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	passwd := filepath.Join(dir, "passwd")
+	group := filepath.Join(dir, "group")
+	ioutil.WriteFile(passwd, []byte(testPasswd), 0644)
+	ioutil.WriteFile(group, []byte(testGroup), 0644)
+
+	cases := map[string]execUser{
+		"root":         {uid: 0, gid: 0, groups: []int{0}, home: "/root"},
+		"0":            {uid: 0, gid: 0, groups: []int{0}, home: "/root"},
+		"app":          {uid: 1000, gid: 1000, groups: []int{1000, 10, 999}, home: "/home/app"},
+		"1000":         {uid: 1000, gid: 1000, groups: []int{1000, 10, 999}, home: "/home/app"},
+		"app:docker":   {uid: 1000, gid: 999, groups: []int{999, 10}, home: "/home/app"},
+		"app:63":       {uid: 1000, gid: 63, groups: []int{63, 10, 999}, home: "/home/app"},
+		"daemon:wheel": {uid: 2, gid: 10, groups: []int{10, 999}, home: "/sbin"},
+		// unknown numeric ids are used as they are
+		"4242":      {uid: 4242, gid: 4242, groups: []int{4242}, home: "/"},
+		"4242:4343": {uid: 4242, gid: 4343, groups: []int{4343}, home: "/"},
+	}
+	for in, want := range cases {
+		got, err := lookupUser(in, passwd, group)
+		if err != nil {
+			t.Errorf("lookupUser(%q) error: %v", in, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("lookupUser(%q) = %+v, want %+v", in, *got, want)
+		}
+	}
+	for _, in := range []string{"nobody", "broken", "app:nogroup", ""} {
+		if got, err := lookupUser(in, passwd, group); err == nil {
+			t.Errorf("lookupUser(%q) = %+v, should fail", in, *got)
+		}
+	}
+
+	// an image without passwd and group files only knows numeric ids
+	missing := filepath.Join(dir, "missing")
+	if got, err := lookupUser("1000:1000", missing, missing); err != nil || got.uid != 1000 || got.gid != 1000 {
+		t.Errorf("lookupUser(1000:1000) without files = %+v, %v", got, err)
+	}
+	if _, err := lookupUser("app", missing, missing); err == nil {
+		t.Error("lookupUser(app) without files should fail")
+	}
+}
@@ -1,99 +1,247 @@
 package container
 
 import (
+	"bufio"
 	"fmt"
-	log "github.com/Sirupsen/logrus"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
+const (
+	MountTypeBind   = "bind"
+	MountTypeVolume = "volume"
+)
+
+// Mount is a host path or a named volume mounted into a container
+type Mount struct {
+	// MountTypeBind or MountTypeVolume
+	Type string `json:"type"`
+	// volume name and driver, empty for bind mounts
+	Name   string `json:"name,omitempty"`
+	Driver string `json:"driver,omitempty"`
+	// host path mounted into the container
+	Source string `json:"source"`
+	// path inside the container
+	Destination string `json:"destination"`
+	RW          bool   `json:"rw"`
+	// volume created for this container only, removed along with it
+	Anonymous bool `json:"anonymous,omitempty"`
+}
+
 //Create an Overlay filesystem as container root workspace
-func NewWorkSpace(volume, imageName, containerName string) {
-	CreateReadOnlyLayer(imageName)
-	CreateWriteLayer(containerName)
+func NewWorkSpace(mounts []*Mount, imageName, containerName string, storageOpt map[string]string) error {
+	if err := CreateReadOnlyLayer(imageName); err != nil {
+		return err
+	}
+	if size := storageOpt["size"]; size != "" {
+		// write_layer and work live on the size limited layer
+		if err := CreateQuotaLayer(containerName, size); err != nil {
+			return fmt.Errorf("Limit write layer size error: %v", err)
+		}
+	}
+	if err := CreateWriteLayer(containerName); err != nil {
+		return err
+	}
 	// For overlayFS
-	CreateWorkdir(containerName)
-	CreateMountPoint(containerName, imageName)
-	if volume != "" {
-		volumeDirs := volumeDirExtract(volume)
-		length := len(volumeDirs)
-		if length == 2 && volumeDirs[0] != "" && volumeDirs[1] != "" {
-			MountVolume(volumeDirs, containerName)
-			log.Infof("%q", volumeDirs)
-		} else {
-			log.Infof("Volume parameter input is not correct. %s", volume)
+	if err := CreateWorkdir(containerName); err != nil {
+		return err
+	}
+	return MountWorkSpace(mounts, imageName, containerName)
+}
+
+// MountWorkSpace mounts the root and the volumes of an existing workspace
+// again, skipping whatever is still mounted
+func MountWorkSpace(mounts []*Mount, imageName, containerName string) error {
+	if err := remountQuotaLayer(containerName); err != nil {
+		return err
+	}
+	if mounted, _ := IsMounted(ContainerMntPath(containerName)); !mounted {
+		if err := CreateMountPoint(containerName, imageName); err != nil {
+			return err
 		}
 	}
+	for _, m := range mounts {
+		if target, err := volumeTarget(m, containerName); err == nil {
+			if mounted, _ := IsMounted(target); mounted {
+				continue
+			}
+		}
+		if err := MountVolume(m, containerName); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func CreateReadOnlyLayer(imageName string) {
+func CreateReadOnlyLayer(imageName string) error {
 	imageDir := layerPath(imageName)
 	exist, err := PathExists(imageDir)
 	if err != nil {
-		log.Infof("Fail to judge whether dir %s exists. %v", imageDir, err)
-		return
+		return fmt.Errorf("Fail to judge whether dir %s exists. %v", imageDir, err)
 	}
 	if exist == false {
-		log.Errorf("Image not found. Run docker pull <image> && docker export first.")
+		return fmt.Errorf("Image %s not found. Run docker pull <image> && docker export first.", imageName)
 	}
+	return nil
 }
 
-func CreateWriteLayer(containerName string) {
-	writeDir := containerWriteLayerPath(containerName)
+func CreateWriteLayer(containerName string) error {
+	writeDir := ContainerWriteLayerPath(containerName)
 	if err := os.MkdirAll(writeDir, 0777); err != nil {
-		log.Errorf("Mkdir dir %s error. %v", writeDir, err)
+		return fmt.Errorf("Mkdir dir %s error. %v", writeDir, err)
 	}
+	return nil
 }
 
-func CreateWorkdir(containerName string) {
+// RenameWorkSpace moves the layers of a container, mounted or not, to
+// the paths of its new name
+func RenameWorkSpace(oldName, newName string) error {
+	oldPath, newPath := layerPath(oldName), layerPath(newName)
+	if exists, _ := PathExists(newPath); exists {
+		return fmt.Errorf("Layer %s already exists", newPath)
+	}
+	mounted, err := IsMounted(oldPath)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		// a quota layer cannot be renamed, it is moved with the overlay on
+		// it onto a private bind of the new dir, the old bind is dropped
+		if err := os.Mkdir(newPath, 0777); err != nil {
+			return err
+		}
+		if err := privateLayerDir(newName); err != nil {
+			os.Remove(newPath)
+			return err
+		}
+		if output, err := exec.Command("mount", "--move", oldPath, newPath).CombinedOutput(); err != nil {
+			unmountQuotaLayer(newName)
+			os.Remove(newPath)
+			return fmt.Errorf("Move mount %s error: %v %s", oldPath, err, output)
+		}
+		if err := unmountQuotaLayer(oldName); err != nil {
+			return err
+		}
+		os.Remove(oldPath)
+	} else if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	for _, p := range []func(string) string{quotaImagePath, quotaProjectIdPath} {
+		if err := os.Rename(p(oldName), p(newName)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GraphDriverData lists the overlay directories of a container
+func GraphDriverData(imageName, containerName string) map[string]string {
+	return map[string]string{
+		"LowerDir":  layerPath(imageName),
+		"UpperDir":  ContainerWriteLayerPath(containerName),
+		"MergedDir": ContainerMntPath(containerName),
+		"WorkDir":   containerWorkPath(containerName, "image"),
+	}
+}
+
+func CreateWorkdir(containerName string) error {
 	workDir := containerWorkPath(containerName, "image")
 	if err := os.MkdirAll(workDir, 0777); err != nil {
-		log.Errorf("Mkdir dir %s error. %v", workDir, err)
+		return fmt.Errorf("Mkdir dir %s error. %v", workDir, err)
 	}
+	return nil
 }
 
-func CreateMountPoint(containerName, imageName string) {
+func CreateMountPoint(containerName, imageName string) error {
 	mntDir := ContainerMntPath(containerName)
 	if err := os.MkdirAll(mntDir, 0777); err != nil {
-		log.Errorf("Mkdir dir %s error. %v", mntDir, err)
+		return fmt.Errorf("Mkdir dir %s error. %v", mntDir, err)
 	}
 	dirs := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s",
 		layerPath(imageName),
-		containerWriteLayerPath(containerName),
+		ContainerWriteLayerPath(containerName),
 		containerWorkPath(containerName, "image"))
 	cmd := exec.Command("mount", "-t", "overlay", "-o", dirs, "none", mntDir)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		log.Errorf("%v", err)
+		return fmt.Errorf("Mount overlay on %s error: %v", mntDir, err)
 	}
+	return nil
 }
 
-func DeleteWorkSpace(volume, containerName, imageName string) {
-	if volume != "" {
-		volumeDirs := volumeDirExtract(volume)
-		length := len(volumeDirs)
-		if length == 2 && volumeDirs[0] != "" && volumeDirs[1] != "" {
-			UmountVolume(volumeDirs, containerName)
-		}
+// DeleteWorkSpace removes the layers of a container. Nothing is removed
+// while anything of it is still mounted, that would reach into the mounts.
+func DeleteWorkSpace(mounts []*Mount, containerName, imageName string) error {
+	if err := UnmountWorkSpace(mounts, containerName); err != nil {
+		return err
+	}
+	if err := DeleteQuotaLayer(containerName); err != nil {
+		return err
 	}
-	UnmountMountPoint(containerName)
 	containerLayerPath := layerPath(containerName)
+	mountPoints, err := mountPointsUnder(containerLayerPath)
+	if err != nil {
+		return err
+	}
+	if len(mountPoints) > 0 {
+		return fmt.Errorf("Layer %s is still mounted on %s", containerLayerPath, strings.Join(mountPoints, ", "))
+	}
 	if err := os.RemoveAll(containerLayerPath); err != nil {
-		log.Errorf("Remove dir %s error: %v", containerLayerPath, err)
+		return fmt.Errorf("Remove dir %s error: %v", containerLayerPath, err)
 	}
+	return nil
 }
 
-func UnmountMountPoint(containerName string) {
+// UnmountWorkSpace unmounts whatever is still mounted of the root and the
+// volumes of a container, keeping its layers. It stops at the first
+// mount failing to unmount.
+func UnmountWorkSpace(mounts []*Mount, containerName string) error {
+	// nested mounts go first
+	for i := len(mounts) - 1; i >= 0; i-- {
+		if target, err := volumeTarget(mounts[i], containerName); err == nil {
+			if mounted, _ := IsMounted(target); !mounted {
+				continue
+			}
+		}
+		if err := UmountVolume(mounts[i], containerName); err != nil {
+			return err
+		}
+	}
+	if mounted, _ := IsMounted(ContainerMntPath(containerName)); mounted {
+		return UnmountMountPoint(containerName)
+	}
+	return nil
+}
+
+func UnmountMountPoint(containerName string) error {
 	mntDir := ContainerMntPath(containerName)
-	cmd := exec.Command("umount", mntDir)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Errorf("Umount mntDir error: %v", err)
+	if output, err := exec.Command("umount", mntDir).CombinedOutput(); err != nil {
+		return fmt.Errorf("Umount %s error: %v %s", mntDir, err, output)
+	}
+	return nil
+}
+
+// mountPointsUnder lists what is mounted on path or below it
+func mountPointsUnder(path string) ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	path = filepath.Clean(path)
+	var mountPoints []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), " ")
+		if len(fields) > 4 && (fields[4] == path || strings.HasPrefix(fields[4], path+"/")) {
+			mountPoints = append(mountPoints, fields[4])
+		}
 	}
+	return mountPoints, scanner.Err()
 }
 
 func PathExists(path string) (bool, error) {
@@ -107,44 +255,41 @@ func PathExists(path string) (bool, error) {
 	return false, err
 }
 
-func volumeDirExtract(volume string) []string {
-	var volumeDirs []string
-	volumeDirs = strings.Split(volume, ":")
-	return volumeDirs
+// volumeTarget is where the mount destination lives on the host, inside the container root
+func volumeTarget(m *Mount, containerName string) (string, error) {
+	return ResolveInRoot(ContainerMntPath(containerName), m.Destination)
 }
 
-func MountVolume(volumeDirs []string, containerName string) {
-	volumeLowerDir := layerPath("volume_lowerdir")
-	volumeUpperDir := volumeDirs[0]
-	volumeWorkDir := containerWorkPath(containerName, "volume")
-
-	if err := os.Mkdir(volumeLowerDir, 0777); err != nil {
-		log.Infof("Mkdir host dir %s error: %v", volumeLowerDir, err)
-	}
-	if err := os.Mkdir(volumeWorkDir, 0777); err != nil {
-		log.Infof("Mkdir host dir %s error: %v", volumeWorkDir, err)
+// Bind mount the host path m.Source onto m.Destination inside the container.
+// The host path comes from a volume driver and may live on any filesystem.
+func MountVolume(m *Mount, containerName string) error {
+	containerVolumeDir, err := volumeTarget(m, containerName)
+	if err != nil {
+		return fmt.Errorf("Resolve volume destination %s error: %v", m.Destination, err)
 	}
 	// Create mount point inside container
-	containerDir := volumeDirs[1]
-	containerVolumeDir := filepath.Join(ContainerMntPath(containerName), containerDir)
-	if err := os.Mkdir(containerVolumeDir, 0777); err != nil {
-		log.Infof("Mkdir container dir %s error: %v", containerVolumeDir, err)
+	if err := os.MkdirAll(containerVolumeDir, 0777); err != nil {
+		return fmt.Errorf("Mkdir container dir %s error: %v", containerVolumeDir, err)
 	}
-	dirs := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s",
-		volumeLowerDir,
-		volumeUpperDir,
-		volumeWorkDir)
-	cmd := exec.Command("mount", "-t", "overlay", "-o", dirs, "none", containerVolumeDir)
-	if err := cmd.Run(); err != nil {
-		log.Errorf("Mount volume failed error: %v", err)
+	if output, err := exec.Command("mount", "--bind", m.Source, containerVolumeDir).CombinedOutput(); err != nil {
+		return fmt.Errorf("Mount volume %s failed error: %v %s", m.Destination, err, output)
+	}
+	if !m.RW {
+		if output, err := exec.Command("mount", "-o", "remount,bind,ro", containerVolumeDir).CombinedOutput(); err != nil {
+			exec.Command("umount", containerVolumeDir).Run()
+			return fmt.Errorf("Remount volume %s read-only error: %v %s", m.Destination, err, output)
+		}
 	}
+	return nil
 }
 
-func UmountVolume(volumeDirs []string, containerName string) {
-	containerDir := volumeDirs[1]
-	containerVolumeDir := filepath.Join(ContainerMntPath(containerName), containerDir)
-	cmd := exec.Command("umount", containerVolumeDir)
-	if err := cmd.Run(); err != nil {
-		log.Errorf("Umount volume failed error: %v", err)
+func UmountVolume(m *Mount, containerName string) error {
+	containerVolumeDir, err := volumeTarget(m, containerName)
+	if err != nil {
+		return fmt.Errorf("Resolve volume destination %s error: %v", m.Destination, err)
+	}
+	if output, err := exec.Command("umount", containerVolumeDir).CombinedOutput(); err != nil {
+		return fmt.Errorf("Umount volume %s error: %v %s", m.Destination, err, output)
 	}
+	return nil
 }
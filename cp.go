@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// splitCpArg splits "container:path", a local path or "-" has no container
+func splitCpArg(arg string) (string, string) {
+	if strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, ".") {
+		return "", arg
+	}
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) < 2 || parts[0] == "" {
+		return "", arg
+	}
+	return parts[0], parts[1]
+}
+
+func copyContainer(src, dst string) error {
+	srcContainer, srcPath := splitCpArg(src)
+	dstContainer, dstPath := splitCpArg(dst)
+	var err error
+	if srcContainer != "" {
+		if srcContainer, err = resolveContainerName(srcContainer); err != nil {
+			return err
+		}
+	}
+	if dstContainer != "" {
+		if dstContainer, err = resolveContainerName(dstContainer); err != nil {
+			return err
+		}
+	}
+	switch {
+	case srcContainer != "" && dstContainer != "":
+		return fmt.Errorf("Copying between containers is not supported")
+	case srcContainer != "":
+		return copyFromContainer(srcContainer, srcPath, dstPath)
+	case dstContainer != "":
+		return copyToContainer(srcPath, dstContainer, dstPath)
+	}
+	return fmt.Errorf("Either source or destination must be a container path")
+}
+
+// containerRoot returns the merged dir of the container. If the container
+// is stopped and its overlay is not mounted, it is mounted along with its
+// volumes for the copy, and starting the container waits until released.
+func containerRoot(containerName string) (string, func(), error) {
+	unlock, err := lockWorkspace(containerName)
+	if err != nil {
+		return "", nil, err
+	}
+	containerInfo, err := GetContainerInfoByName(containerName)
+	if err != nil {
+		unlock()
+		return "", nil, fmt.Errorf("Get container %s info error: %v", containerName, err)
+	}
+	mntDir := container.ContainerMntPath(containerName)
+	mounted, err := container.IsMounted(mntDir)
+	if err != nil {
+		unlock()
+		return "", nil, err
+	}
+	if mounted {
+		// the workspace of a running container
+		unlock()
+		return mntDir, func() {}, nil
+	}
+	if err := container.MountWorkSpace(containerInfo.Mounts, containerInfo.Image, containerName); err != nil {
+		if err := container.UnmountWorkSpace(containerInfo.Mounts, containerName); err != nil {
+			log.Errorf("Unmount container %s filesystem error: %v", containerName, err)
+		}
+		unlock()
+		return "", nil, fmt.Errorf("Cannot mount container %s filesystem: %v", containerName, err)
+	}
+	return mntDir, func() {
+		if err := container.UnmountWorkSpace(containerInfo.Mounts, containerName); err != nil {
+			log.Errorf("Unmount container %s filesystem error: %v", containerName, err)
+		}
+		unlock()
+	}, nil
+}
+
+func copyFromContainer(containerName, srcPath, dst string) error {
+	root, release, err := containerRoot(containerName)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// the path is resolved by the helper chrooted into the container, so
+	// that swapping in a symlink cannot reach out of the container
+	srcPath = filepath.Join("/", srcPath)
+	tarCmd := exec.Command("/proc/self/exe", "tar", root, srcPath)
+	tarCmd.Stderr = os.Stderr
+	if dst == "-" {
+		tarCmd.Stdout = os.Stdout
+		if err := tarCmd.Run(); err != nil {
+			return fmt.Errorf("Copy %s from container %s error: %v", srcPath, containerName, err)
+		}
+		return nil
+	}
+
+	dir, rename, to := dst, "", ""
+	if dstInfo, err := os.Stat(dst); err != nil || !dstInfo.IsDir() {
+		// copy as the destination name instead of into it
+		rename = filepath.Base(srcPath)
+		to = filepath.Base(dst)
+		dir = filepath.Dir(dst)
+	}
+	tarStream, err := tarCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := tarCmd.Start(); err != nil {
+		return err
+	}
+	err = container.Untar(tarStream, dir, rename, to)
+	if err != nil {
+		tarCmd.Process.Kill()
+	}
+	if waitErr := tarCmd.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return fmt.Errorf("Copy %s from container %s error: %v", srcPath, containerName, err)
+	}
+	return nil
+}
+
+func copyToContainer(src, containerName, dstPath string) error {
+	root, release, err := containerRoot(containerName)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	resolved, err := container.ResolveInRoot(root, dstPath)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil {
+		return err
+	}
+	// the path as seen from inside the container
+	dir := filepath.Join("/", rel)
+	dstInfo, dstErr := os.Stat(resolved)
+
+	var tarStream io.Reader
+	var rename, to string
+	var tarCmd *exec.Cmd
+	if src == "-" {
+		if dstErr != nil || !dstInfo.IsDir() {
+			return fmt.Errorf("Destination %s must be a directory in container %s", dstPath, containerName)
+		}
+		tarStream = os.Stdin
+	} else {
+		src, err = filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(src); err != nil {
+			return err
+		}
+		if dstErr != nil || !dstInfo.IsDir() {
+			// copy as the destination name instead of into it
+			rename = filepath.Base(src)
+			to = filepath.Base(dir)
+			dir = filepath.Dir(dir)
+		}
+		tarCmd = exec.Command("tar", "-cf", "-", "-C", filepath.Dir(src), filepath.Base(src))
+		tarCmd.Stderr = os.Stderr
+		if tarStream, err = tarCmd.StdoutPipe(); err != nil {
+			return err
+		}
+		if err := tarCmd.Start(); err != nil {
+			return err
+		}
+	}
+
+	untarCmd := exec.Command("/proc/self/exe", "untar", root, dir, rename, to)
+	untarCmd.Stdin = tarStream
+	// the helper logs to stdout, which carries nothing here
+	untarCmd.Stdout = os.Stderr
+	untarCmd.Stderr = os.Stderr
+	err = untarCmd.Run()
+	if tarCmd != nil {
+		if waitErr := tarCmd.Wait(); err == nil {
+			err = waitErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Copy %s to container %s error: %v", src, containerName, err)
+	}
+	return nil
+}
+
+// untarInRoot extracts stdin into dir after chrooting into root,
+// so symlinks are resolved inside the container only
+func untarInRoot(root, dir, rename, to string) error {
+	if err := syscall.Chroot(root); err != nil {
+		return fmt.Errorf("Chroot %s error: %v", root, err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	return container.Untar(os.Stdin, dir, rename, to)
+}
+
+// tarInRoot writes a tar stream of path to stdout after chrooting into
+// root, so symlinks are resolved inside the container only
+func tarInRoot(root, path string) error {
+	if err := syscall.Chroot(root); err != nil {
+		return fmt.Errorf("Chroot %s error: %v", root, err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(path); err != nil {
+		return fmt.Errorf("No such file %s in container", path)
+	}
+	return container.Tar(os.Stdout, path)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+	"github.com/seagullbird/mydocker/network"
+	"github.com/seagullbird/mydocker/volume"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// reserveContainerName creates the info directory of a container, which
+// fails if another container holds the name already
+func reserveContainerName(containerName string) error {
+	if !containerNamePattern.MatchString(containerName) {
+		return fmt.Errorf("Invalid container name %s, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", containerName)
+	}
+	containerInfoDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	if err := os.MkdirAll(filepath.Dir(containerInfoDir), 0755); err != nil {
+		return err
+	}
+	if err := os.Mkdir(containerInfoDir, 0755); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("Conflict, the container name %s is already in use", containerName)
+		}
+		return err
+	}
+	return nil
+}
+
+// createContainer prepares the root filesystem, volumes and ip address of
+// a container and records it as created, without running anything
+func createContainer(containerInfo *container.ContainerInfo) (err error) {
+	containerInfo.Id = newContainerID()
+	if containerInfo.Name == "" {
+		containerInfo.Name = shortID(containerInfo.Id)
+	}
+	if err := reserveContainerName(containerInfo.Name); err != nil {
+		return err
+	}
+	var undo rollback
+	defer func() {
+		if err != nil {
+			undo.run()
+		}
+	}()
+	undo.add(func() { deleteContainerInfo(containerInfo.Name) })
+	imageConfig, err := container.LoadImageConfig(containerInfo.Image)
+	if err != nil {
+		return fmt.Errorf("Load image %s config error: %v", containerInfo.Image, err)
+	}
+	if containerInfo.StopSignal == "" {
+		containerInfo.StopSignal = imageConfig.StopSignal
+	}
+	if containerInfo.WorkingDir == "" {
+		containerInfo.WorkingDir = imageConfig.WorkingDir
+	}
+	if containerInfo.User == "" {
+		containerInfo.User = imageConfig.User
+	}
+	// container labels override the image's
+	for k, v := range imageConfig.Labels {
+		if _, ok := containerInfo.Labels[k]; !ok {
+			if containerInfo.Labels == nil {
+				containerInfo.Labels = map[string]string{}
+			}
+			containerInfo.Labels[k] = v
+		}
+	}
+	if containerInfo.Healthcheck, err = container.MergeHealthConfig(containerInfo.Healthcheck, imageConfig.Healthcheck); err != nil {
+		return err
+	}
+	// resolve the volume sources to host paths through their drivers
+	if len(containerInfo.Mounts) > 0 {
+		if err := volume.Init(); err != nil {
+			return err
+		}
+	}
+	for _, m := range containerInfo.Mounts {
+		m := m
+		if err := volume.Attach(m, containerInfo.Id); err != nil {
+			return fmt.Errorf("Attach volume %s error: %v", m.Destination, err)
+		}
+		undo.add(func() {
+			volume.Detach(m, containerInfo.Id)
+			if m.Anonymous {
+				volume.RemoveVolume(m.Name)
+			}
+		})
+	}
+	undo.add(func() {
+		if err := container.DeleteWorkSpace(containerInfo.Mounts, containerInfo.Name, containerInfo.Image); err != nil {
+			log.Errorf("Delete workspace error: %v", err)
+		}
+	})
+	if err := container.NewWorkSpace(containerInfo.Mounts, containerInfo.Image, containerInfo.Name, containerInfo.StorageOpt); err != nil {
+		return fmt.Errorf("New workspace error: %v", err)
+	}
+	if containerInfo.Network != "" {
+		network.Init()
+		if _, err := network.Reserve(containerInfo.Network, containerInfo); err != nil {
+			return fmt.Errorf("Reserve ip address error: %v", err)
+		}
+		undo.add(func() { network.Release(containerInfo.Network, containerInfo) })
+	}
+
+	containerInfo.Command = strings.Join(containerInfo.Args, " ")
+	containerInfo.CreatedTime = time.Now().Format(time.RFC3339Nano)
+	containerInfo.Status = container.CREATED
+	return recordContainerInfo(containerInfo)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"github.com/seagullbird/mydocker/container"
+	"path/filepath"
+	"strings"
+)
+
+func diffContainer(containerName string) error {
+	containerInfo, err := GetContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("Get container %s info error %v", containerName, err)
+	}
+	changes, err := container.ContainerChanges(containerName, containerInfo.Image)
+	if err != nil {
+		return fmt.Errorf("Diff container %s error: %v", containerName, err)
+	}
+	for _, change := range changes {
+		if change.Kind == container.ChangeAdd && isMountPoint(containerInfo.Mounts, change.Path) {
+			continue
+		}
+		fmt.Printf("%s %s\n", change.Kind, change.Path)
+	}
+	return nil
+}
+
+// isMountPoint reports whether path is a volume destination, lies below one
+// or leads to one, the mount points created in the write layer are not
+// container changes
+func isMountPoint(mounts []*container.Mount, path string) bool {
+	for _, m := range mounts {
+		dest := filepath.Clean("/" + m.Destination)
+		if path == dest || strings.HasPrefix(path, dest+"/") || strings.HasPrefix(dest, path+"/") {
+			return true
+		}
+	}
+	return false
+}
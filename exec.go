@@ -3,10 +3,12 @@ package main
 import (
 	"fmt"
 	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
 	_ "github.com/seagullbird/mydocker/nsenter"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
@@ -21,37 +23,47 @@ func ExecContainer(containerName string, cmdArray []string) {
 	}
 
 	cmdStr := strings.Join(cmdArray, " ")
-	log.Infof("container pid %s", pid)
+	log.Infof("container pid %d", pid)
 	log.Infof("command %s", cmdStr)
 
-	cmd := exec.Command("/proc/self/exe", "exec")
+	cmd := execInContainerCommand(pid, cmdStr)
 	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
 	cmd.Stderr = os.Stderr
 
-	os.Setenv(ENV_EXEC_PID, pid)
-	os.Setenv(ENV_EXEC_CMD, cmdStr)
-	// get container envs
-	containerEnvs := getEnvsByPid(pid)
-	cmd.Env = append(os.Environ(), containerEnvs...)
-
 	if err := cmd.Run(); err != nil {
 		log.Errorf("Exec container %s error: %v", containerName, err)
 	}
 }
 
-func getContainerPidByName(containerName string) (string, error) {
+// execInContainerCommand prepares a command run by a shell inside the
+// namespaces of the container process pid, with the container's envs
+func execInContainerCommand(pid int, cmdStr string) *exec.Cmd {
+	cmd := exec.Command("/proc/self/exe", "exec")
+	cmd.Env = append(os.Environ(), ENV_EXEC_PID+"="+strconv.Itoa(pid), ENV_EXEC_CMD+"="+cmdStr)
+	// get container envs
+	cmd.Env = append(cmd.Env, getEnvsByPid(pid)...)
+	return cmd
+}
+
+func getContainerPidByName(containerName string) (int, error) {
 	containerInfo, err := GetContainerInfoByName(containerName)
 	if err != nil {
 		log.Errorf("GetContainerInfoByName with name %s error: %v", containerName, err)
-		return "", err
+		return 0, err
+	}
+	if containerInfo.Status == container.PAUSED {
+		return 0, fmt.Errorf("Container %s is paused, unpause it first", containerName)
+	}
+	if containerInfo.Pid == 0 {
+		return 0, fmt.Errorf("Container %s is not running", containerName)
 	}
 	return containerInfo.Pid, nil
 }
 
-func getEnvsByPid(pid string) []string {
+func getEnvsByPid(pid int) []string {
 	// /proc/PID/environ saves process's env
-	path := fmt.Sprintf("/proc/%s/environ", pid)
+	path := fmt.Sprintf("/proc/%d/environ", pid)
 	contentBytes, err := ioutil.ReadFile(path)
 	if err != nil {
 		log.Errorf("Read file %s error: %v", path, err)
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// startHealthcheck probes a container every interval until the returned
+// channel is closed. With a restart policy an unhealthy container is
+// killed, so that its monitor restarts it.
+func startHealthcheck(id string, config *container.HealthConfig, pid int) chan struct{} {
+	done := make(chan struct{})
+	if config == nil {
+		return done
+	}
+	cmdStr, _ := config.Command()
+	startedAt := time.Now()
+	go func() {
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			result := runHealthProbe(pid, cmdStr, config.Timeout)
+			counts := time.Since(startedAt) >= config.StartPeriod
+			becameUnhealthy := false
+			var restartPolicy *container.RestartPolicy
+			var containerName string
+			err := updateContainerInfoByID(id, func(info *container.ContainerInfo) error {
+				containerName = info.Name
+				// the container has exited or been paused meanwhile
+				if info.Pid != pid || info.Status != container.RUNNING || info.Health == nil {
+					return nil
+				}
+				wasUnhealthy := info.Health.Status == container.Unhealthy
+				info.Health.Record(result, counts, config.Retries)
+				becameUnhealthy = !wasUnhealthy && info.Health.Status == container.Unhealthy
+				restartPolicy = info.RestartPolicy
+				return nil
+			})
+			if err != nil {
+				log.Errorf("Record health of container %s error: %v", id, err)
+				continue
+			}
+			if becameUnhealthy {
+				log.Warnf("Container %s is unhealthy", containerName)
+				if restartPolicy != nil && restartPolicy.Name != container.RestartNo {
+					log.Infof("Killing unhealthy container %s for its restart policy", containerName)
+					syscall.Kill(pid, syscall.SIGKILL)
+				}
+			}
+		}
+	}()
+	return done
+}
+
+// runHealthProbe runs cmdStr inside the container, a probe which does not
+// finish within timeout fails
+func runHealthProbe(pid int, cmdStr string, timeout time.Duration) *container.HealthResult {
+	result := &container.HealthResult{Start: time.Now().Format(time.RFC3339Nano)}
+	var output bytes.Buffer
+	cmd := execInContainerCommand(pid, cmdStr)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// the shell and its children are killed with the probe on timeout
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err := cmd.Start()
+	if err == nil {
+		timer := time.AfterFunc(timeout, func() {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		})
+		err = cmd.Wait()
+		if !timer.Stop() {
+			result.End = time.Now().Format(time.RFC3339Nano)
+			result.ExitCode = -1
+			result.Output = "Health check exceeded timeout (" + timeout.String() + ")"
+			return result
+		}
+	}
+	result.End = time.Now().Format(time.RFC3339Nano)
+	result.Output = output.String()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		result.ExitCode = exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+	} else if err != nil {
+		result.ExitCode = -1
+		result.Output = err.Error()
+	}
+	return result
+}
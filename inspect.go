@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/cgroups/subsystems"
+	"github.com/seagullbird/mydocker/container"
+	"github.com/seagullbird/mydocker/network"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// containerInspect is the inspect document of a container, in the shape
+// of `docker inspect` so that the same --format templates work
+type containerInspect struct {
+	Id              string
+	Name            string
+	Image           string
+	Created         string
+	Path            string
+	Args            []string
+	State           inspectState
+	Config          inspectConfig
+	HostConfig      inspectHostConfig
+	Mounts          []*container.Mount
+	NetworkSettings inspectNetworkSettings
+	GraphDriver     inspectGraphDriver
+	LogPath         string
+}
+
+type inspectState struct {
+	Status       string
+	Running      bool
+	Paused       bool
+	Restarting   bool
+	OOMKilled    bool
+	Pid          int
+	ExitCode     int
+	StartedAt    string
+	FinishedAt   string
+	RestartCount int
+	Health       *container.Health `json:",omitempty"`
+}
+
+type inspectConfig struct {
+	Cmd         []string
+	Env         []string
+	WorkingDir  string
+	User        string
+	Labels      map[string]string
+	Tty         bool
+	StopSignal  string
+	Healthcheck *container.HealthConfig `json:",omitempty"`
+}
+
+type inspectHostConfig struct {
+	Resources      *subsystems.ResourceConfig
+	RestartPolicy  *container.RestartPolicy
+	AutoRemove     bool
+	ReadonlyRootfs bool
+	Tmpfs          []string
+	StorageOpt     map[string]string
+	Ulimits        []*container.Rlimit
+}
+
+type inspectNetworkSettings struct {
+	Ports    map[string][]portBinding
+	Networks map[string]*endpointSettings
+}
+
+type portBinding struct {
+	HostIp   string
+	HostPort string
+}
+
+type endpointSettings struct {
+	EndpointID  string
+	Gateway     string
+	IPAddress   string
+	IPPrefixLen int
+}
+
+type inspectGraphDriver struct {
+	Name string
+	Data map[string]string
+}
+
+func newContainerInspect(info *container.ContainerInfo) *containerInspect {
+	c := &containerInspect{
+		Id:      info.Id,
+		Name:    info.Name,
+		Image:   info.Image,
+		Created: info.CreatedTime,
+		Args:    []string{},
+		State: inspectState{
+			Status:       info.Status,
+			Running:      info.Status == container.RUNNING || info.Status == container.PAUSED,
+			Paused:       info.Status == container.PAUSED,
+			Restarting:   info.Status == container.RESTARTING,
+			OOMKilled:    info.OOMKilled,
+			ExitCode:     info.ExitCode,
+			StartedAt:    info.StartedAt,
+			FinishedAt:   info.FinishedAt,
+			RestartCount: info.RestartCount,
+			Health:       info.Health,
+		},
+		Config: inspectConfig{
+			Cmd:         info.Args,
+			Env:         info.Env,
+			WorkingDir:  info.WorkingDir,
+			User:        info.User,
+			Labels:      info.Labels,
+			Tty:         info.Tty,
+			StopSignal:  info.StopSignal,
+			Healthcheck: info.Healthcheck,
+		},
+		HostConfig: inspectHostConfig{
+			Resources:      info.Resources,
+			RestartPolicy:  info.RestartPolicy,
+			AutoRemove:     info.AutoRemove,
+			ReadonlyRootfs: info.ReadOnly,
+			Tmpfs:          info.Tmpfs,
+			StorageOpt:     info.StorageOpt,
+			Ulimits:        info.Ulimits,
+		},
+		Mounts: info.Mounts,
+		NetworkSettings: inspectNetworkSettings{
+			Ports:    map[string][]portBinding{},
+			Networks: map[string]*endpointSettings{},
+		},
+		GraphDriver: inspectGraphDriver{
+			Name: "overlay2",
+			Data: container.GraphDriverData(info.Image, info.Name),
+		},
+	}
+	if len(info.Args) > 0 {
+		c.Path = info.Args[0]
+		c.Args = info.Args[1:]
+	}
+	if c.State.Running {
+		c.State.Pid = info.Pid
+	}
+	if !info.Tty {
+		c.LogPath = fmt.Sprintf(container.DefaultInfoLocation, info.Name) + "/" + container.ContainerLogFile
+	}
+	for _, pm := range info.PortMapping {
+		parts := strings.Split(pm, ":")
+		if len(parts) != 2 {
+			continue
+		}
+		port := parts[1] + "/tcp"
+		c.NetworkSettings.Ports[port] = append(c.NetworkSettings.Ports[port], portBinding{HostIp: "0.0.0.0", HostPort: parts[0]})
+	}
+	if info.Network != "" {
+		endpoint := &endpointSettings{}
+		if info.IPAddress != nil {
+			endpoint.EndpointID = fmt.Sprintf("%s-%s", info.Id, info.Network)
+			endpoint.IPAddress = info.IPAddress.String()
+		}
+		if nw, err := network.GetNetwork(info.Network); err == nil {
+			endpoint.Gateway = nw.IpRange.IP.String()
+			endpoint.IPPrefixLen, _ = nw.IpRange.Mask.Size()
+		}
+		c.NetworkSettings.Networks[info.Network] = endpoint
+	}
+	return c
+}
+
+// inspectContainers prints the inspect documents of containers as a JSON
+// array, or each one through the Go template format
+func inspectContainers(containerNames []string, format string) error {
+	var tmpl *template.Template
+	if format != "" {
+		var err error
+		tmpl, err = template.New("format").Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+			"join":  strings.Join,
+			"upper": strings.ToUpper,
+			"lower": strings.ToLower,
+		}).Parse(format)
+		if err != nil {
+			return fmt.Errorf("Parse format error: %v", err)
+		}
+	}
+	network.Init()
+	var inspects []*containerInspect
+	for _, ref := range containerNames {
+		containerName, err := resolveContainerName(ref)
+		if err != nil {
+			log.Errorf("%v", err)
+			continue
+		}
+		containerInfo, err := GetContainerInfoByName(containerName)
+		if err != nil {
+			log.Errorf("Get container %s info error: %v", containerName, err)
+			continue
+		}
+		inspects = append(inspects, newContainerInspect(containerInfo))
+	}
+	if tmpl != nil {
+		for _, c := range inspects {
+			if err := tmpl.Execute(os.Stdout, c); err != nil {
+				return fmt.Errorf("Execute format error: %v", err)
+			}
+			fmt.Println()
+		}
+	} else {
+		content, err := json.MarshalIndent(inspects, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+	}
+	if len(inspects) < len(containerNames) {
+		return fmt.Errorf("Some containers could not be inspected")
+	}
+	return nil
+}
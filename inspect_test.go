@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/seagullbird/mydocker/container"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// lookupPath follows a dotted path of keys in a decoded JSON document
+func lookupPath(doc interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = m[key]
+	}
+	return doc
+}
+
+func TestContainerInspectDocument(t *testing.T) {
+	info := &container.ContainerInfo{
+		Id:          "abc123",
+		Name:        "web",
+		Image:       "busybox",
+		Pid:         4242,
+		Status:      container.RUNNING,
+		Args:        []string{"sh", "-c", "httpd -f"},
+		Env:         []string{"PATH=/bin"},
+		User:        "app",
+		Labels:      map[string]string{"tier": "front"},
+		PortMapping: []string{"8080:80"},
+		RestartPolicy: &container.RestartPolicy{
+			Name: container.RestartOnFailure, MaximumRetryCount: 3,
+		},
+		RestartCount: 2,
+	}
+	content, err := json.Marshal(newContainerInspect(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatal(err)
+	}
+	// the docker inspect paths --format templates are written against
+	want := map[string]interface{}{
+		"Id":                           "abc123",
+		"Name":                         "web",
+		"Image":                        "busybox",
+		"Path":                         "sh",
+		"Args":                         []interface{}{"-c", "httpd -f"},
+		"State.Status":                 "running",
+		"State.Running":                true,
+		"State.Paused":                 false,
+		"State.Pid":                    float64(4242),
+		"State.RestartCount":           float64(2),
+		"Config.Cmd":                   []interface{}{"sh", "-c", "httpd -f"},
+		"Config.Env":                   []interface{}{"PATH=/bin"},
+		"Config.User":                  "app",
+		"Config.Labels.tier":           "front",
+		"GraphDriver.Name":             "overlay2",
+		"GraphDriver.Data.UpperDir":    container.ContainerWriteLayerPath("web"),
+		"NetworkSettings.Ports.80/tcp": []interface{}{map[string]interface{}{"HostIp": "0.0.0.0", "HostPort": "8080"}},
+	}
+	for path, value := range want {
+		if got := lookupPath(doc, path); !reflect.DeepEqual(got, value) {
+			t.Errorf("%s = %#v, want %#v", path, got, value)
+		}
+	}
+	if logPath, _ := lookupPath(doc, "LogPath").(string); logPath == "" {
+		t.Error("LogPath of a detached container is empty")
+	}
+
+	tmpl := template.Must(template.New("format").Parse("{{.Name}} {{.State.Status}} {{.State.Pid}} {{index .Config.Labels \"tier\"}} {{.HostConfig.RestartPolicy.Name}}:{{.HostConfig.RestartPolicy.MaximumRetryCount}}"))
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, newContainerInspect(info)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "web running 4242 front on-failure:3" {
+		t.Errorf("format output = %q", out.String())
+	}
+
+	// a stopped container reports no pid and empty, not null, arguments
+	info.Status = container.Exit
+	info.Pid = 0
+	info.Args = nil
+	stopped := newContainerInspect(info)
+	if stopped.State.Running || stopped.State.Pid != 0 {
+		t.Errorf("stopped container state = %+v", stopped.State)
+	}
+	if content, _ := json.Marshal(stopped.Args); string(content) != "[]" {
+		t.Errorf("Args of a container without command = %s, want []", content)
+	}
+}
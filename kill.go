@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+	"syscall"
+	"time"
+)
+
+// killContainer sends signal to the init process of a running container,
+// or to every process in its cgroup with all
+func killContainer(containerName, signal string, all bool) error {
+	containerInfo, err := GetContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("Get container %s info error: %v", containerName, err)
+	}
+	// a reused pid belongs to somebody else
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED || !initAlive(containerInfo) {
+		return fmt.Errorf("Container %s is not running", containerName)
+	}
+	sig, err := container.ParseSignal(signal)
+	if err != nil {
+		return err
+	}
+	pid := containerInfo.Pid
+	pids := []int{pid}
+	if all {
+		if pids, err = containerCgroupManager(containerInfo).GetPids(); err != nil {
+			return fmt.Errorf("Get processes of container %s error: %v", containerName, err)
+		}
+	}
+	for _, p := range pids {
+		if err := syscall.Kill(p, sig); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("Send %v to process %d error: %v", sig, p, err)
+		}
+	}
+
+	// give the process a moment to handle the signal
+	wait := 100 * time.Millisecond
+	if sig == syscall.SIGKILL {
+		wait = killTimeout
+		if containerInfo.Status == container.PAUSED {
+			// v1 frozen processes only die once thawed
+			if err := containerCgroupManager(containerInfo).Thaw(); err != nil {
+				return fmt.Errorf("Thaw container %s error: %v", containerName, err)
+			}
+		}
+	}
+	if !container.WaitProcessExit(pid, wait) {
+		return nil
+	}
+	log.Infof("Container %s exited on %v", containerName, sig)
+	return markContainerExited(containerName)
+}
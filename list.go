@@ -1,45 +1,44 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/seagullbird/mydocker/container"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 func ListContainers() {
-	containersInfoDir := strings.TrimSuffix(fmt.Sprintf(container.DefaultInfoLocation, ""), "/")
-	files, err := ioutil.ReadDir(containersInfoDir)
-	if err != nil {
-		log.Errorf("Read dir %s error: %v", containersInfoDir, err)
-		return
-	}
-	var containerInfos []*container.ContainerInfo
-	for _, file := range files {
-		containerInfo, err := getContainerInfo(file)
-		if err != nil {
-			log.Errorf("Get container %s info error: %v", file.Name(), err)
-			continue
-		}
-		containerInfos = append(containerInfos, containerInfo)
-	}
+	containerInfos := loadContainerInfos()
 
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
 	fmt.Fprint(w, "ID\tNAME\tPID\tIMAGE\tSTATUS\tCOMMAND\tCREATED\n")
 	for _, item := range containerInfos {
+		created := item.CreatedTime
+		if t, err := time.Parse(time.RFC3339Nano, created); err == nil {
+			created = t.Local().Format("2006-01-02 15:04:05")
+		}
+		pid := ""
+		if item.Pid != 0 {
+			pid = strconv.Itoa(item.Pid)
+		}
+		status := item.Status
+		if item.Status == container.RUNNING && item.Health != nil {
+			status = fmt.Sprintf("%s (%s)", status, item.Health.Status)
+		}
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
-			item.Id,
+			shortID(item.Id),
 			item.Name,
-			item.Pid,
+			pid,
 			item.Image,
-			item.Status,
+			status,
 			item.Command,
-			item.CreatedTime)
+			created)
 	}
 	if err := w.Flush(); err != nil {
 		log.Errorf("Flush error: %v", err)
@@ -47,18 +46,46 @@ func ListContainers() {
 	}
 }
 
+// loadContainerInfos reads the info of every container, skipping broken ones
+func loadContainerInfos() []*container.ContainerInfo {
+	containersInfoDir := strings.TrimSuffix(fmt.Sprintf(container.DefaultInfoLocation, ""), "/")
+	files, err := ioutil.ReadDir(containersInfoDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Read dir %s error: %v", containersInfoDir, err)
+		}
+		return nil
+	}
+	var containerInfos []*container.ContainerInfo
+	for _, file := range files {
+		containerInfo, err := getContainerInfo(file)
+		if os.IsNotExist(err) {
+			// a name reserved by a container being created
+			continue
+		}
+		if err != nil {
+			log.Errorf("Get container %s info error: %v", file.Name(), err)
+			continue
+		}
+		containerInfos = append(containerInfos, containerInfo)
+	}
+	return containerInfos
+}
+
 func getContainerInfo(file os.FileInfo) (*container.ContainerInfo, error) {
 	containerName := file.Name()
 	configFileDir := filepath.Join(fmt.Sprintf(container.DefaultInfoLocation, containerName), container.ConfigName)
 	content, err := ioutil.ReadFile(configFileDir)
 	if err != nil {
-		log.Errorf("Read file %s error: %v", configFileDir, err)
+		if !os.IsNotExist(err) {
+			log.Errorf("Read file %s error: %v", configFileDir, err)
+		}
 		return nil, err
 	}
-	var containerInfo container.ContainerInfo
-	if err := json.Unmarshal(content, &containerInfo); err != nil {
+	containerInfo, err := decodeContainerInfo(content)
+	if err != nil {
 		log.Errorf("Json unmarshal error: %v", err)
 		return nil, err
 	}
-	return &containerInfo, nil
+	return containerInfo, nil
 }
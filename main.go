@@ -1,9 +1,14 @@
 package main
 
 import (
+	"fmt"
 	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+	"github.com/seagullbird/mydocker/network"
+	"github.com/seagullbird/mydocker/volume"
 	"github.com/urfave/cli"
 	"os"
+	"path/filepath"
 )
 
 const usage = `mydocker is a simple container runtime implementation.
@@ -14,21 +19,55 @@ func main() {
 	app.Name = "mydocker"
 	app.Usage = usage
 	app.Version = "1.0.0"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "root",
+			Usage:  "root directory of mydocker state",
+			Value:  container.DefaultRootDir,
+			EnvVar: "MYDOCKER_ROOT",
+		},
+	}
 	app.Commands = []cli.Command{
 		initCommand,
+		shimCommand,
 		runCommand,
+		createCommand,
+		startCommand,
 		commitCommand,
+		diffCommand,
+		cpCommand,
+		untarCommand,
+		tarCommand,
 		listCommand,
+		inspectCommand,
 		logCommand,
 		execCommand,
+		waitCommand,
 		stopCommand,
+		restartCommand,
+		renameCommand,
+		killCommand,
+		pauseCommand,
+		unpauseCommand,
 		removeCommand,
 		networkCommand,
+		volumeCommand,
+		systemCommand,
 	}
 
 	app.Before = func(context *cli.Context) error {
 		log.SetFormatter(&log.JSONFormatter{})
 		log.SetOutput(os.Stdout)
+
+		// the root ends up in the paths given to mount and to children
+		// running in other dirs, so a relative one is made absolute
+		root, err := filepath.Abs(context.GlobalString("root"))
+		if err != nil {
+			return fmt.Errorf("Resolve root dir error: %v", err)
+		}
+		container.SetRootDir(root)
+		network.SetRootDir(root)
+		volume.SetRootDir(root)
 		return nil
 	}
 
@@ -6,87 +6,288 @@ import (
 	"github.com/seagullbird/mydocker/cgroups/subsystems"
 	"github.com/seagullbird/mydocker/container"
 	"github.com/seagullbird/mydocker/network"
+	"github.com/seagullbird/mydocker/volume"
 	"github.com/urfave/cli"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// containerFlags are shared by run and create
+var containerFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "it",
+		Usage: "enable tty",
+	},
+	cli.StringFlag{
+		Name:  "m",
+		Usage: "memory limit",
+	},
+	cli.StringFlag{
+		Name:  "cpuset",
+		Usage: "cpuset limit",
+	},
+	cli.StringFlag{
+		Name:  "cpushare",
+		Usage: "cpu share limit",
+	},
+	cli.StringFlag{
+		Name:  "name",
+		Usage: "container name",
+	},
+	cli.StringSliceFlag{
+		Name:  "v",
+		Usage: "volume, hostpath:containerpath[:ro] or name:containerpath[:ro]",
+	},
+	cli.StringFlag{
+		Name:  "volume-driver",
+		Usage: "driver of the named volume",
+	},
+	cli.StringSliceFlag{
+		Name:  "volumes-from",
+		Usage: "mount the volumes of another container, container[:ro|rw]",
+	},
+	cli.StringSliceFlag{
+		Name:  "e",
+		Usage: "set environment variables",
+	},
+	cli.StringFlag{
+		Name:  "net",
+		Usage: "container network",
+	},
+	cli.StringSliceFlag{
+		Name:  "p",
+		Usage: "port mapping",
+	},
+	cli.BoolFlag{
+		Name:  "read-only",
+		Usage: "mount the container root filesystem read-only",
+	},
+	cli.StringSliceFlag{
+		Name:  "tmpfs",
+		Usage: "mount a tmpfs, path[:options]",
+	},
+	cli.StringSliceFlag{
+		Name:  "storage-opt",
+		Usage: "write layer storage options, size=<size> limits its size",
+	},
+	cli.StringFlag{
+		Name:  "stop-signal",
+		Usage: "signal to stop the container, SIGTERM or the image's StopSignal by default",
+	},
+	cli.StringFlag{
+		Name:  "restart",
+		Value: container.RestartNo,
+		Usage: "restart policy when the container exits, no, on-failure[:max-retries], always or unless-stopped",
+	},
+	cli.StringFlag{
+		Name:  "health-cmd",
+		Usage: "command run inside the container to check its health, NONE disables the image's check",
+	},
+	cli.DurationFlag{
+		Name:  "health-interval",
+		Usage: "time between health checks (default 30s)",
+	},
+	cli.DurationFlag{
+		Name:  "health-timeout",
+		Usage: "maximum time a health check may take (default 30s)",
+	},
+	cli.IntFlag{
+		Name:  "health-retries",
+		Usage: "consecutive failures to report unhealthy (default 3)",
+	},
+	cli.DurationFlag{
+		Name:  "health-start-period",
+		Usage: "time for the container to start before failed checks count",
+	},
+	cli.StringFlag{
+		Name:  "w",
+		Usage: "working directory inside the container",
+	},
+	cli.StringFlag{
+		Name:  "u",
+		Usage: "user[:group] to run as, names or ids",
+	},
+	cli.StringSliceFlag{
+		Name:  "label",
+		Usage: "set metadata on the container, key=value",
+	},
+	cli.StringSliceFlag{
+		Name:  "ulimit",
+		Usage: "resource limit, name=soft[:hard], e.g. nofile=1024:2048",
+	},
+}
+
+// newContainerInfo builds the configuration of a new container from
+// containerFlags and the image [command] arguments
+func newContainerInfo(context *cli.Context) (*container.ContainerInfo, error) {
+	if len(context.Args()) < 1 {
+		return nil, fmt.Errorf("Missing container command")
+	}
+	mounts, err := parseMounts(context.StringSlice("v"), context.String("volume-driver"), context.StringSlice("volumes-from"))
+	if err != nil {
+		return nil, err
+	}
+	storageOpt, err := container.ParseStorageOpt(context.StringSlice("storage-opt"))
+	if err != nil {
+		return nil, err
+	}
+	restartPolicy, err := container.ParseRestartPolicy(context.String("restart"))
+	if err != nil {
+		return nil, err
+	}
+	var healthcheck *container.HealthConfig
+	if context.IsSet("health-cmd") || context.IsSet("health-interval") || context.IsSet("health-timeout") ||
+		context.IsSet("health-retries") || context.IsSet("health-start-period") {
+		healthcheck = &container.HealthConfig{
+			Interval:    context.Duration("health-interval"),
+			Timeout:     context.Duration("health-timeout"),
+			Retries:     context.Int("health-retries"),
+			StartPeriod: context.Duration("health-start-period"),
+		}
+		switch cmd := context.String("health-cmd"); cmd {
+		case "":
+		case "NONE":
+			healthcheck.Test = []string{"NONE"}
+		default:
+			healthcheck.Test = []string{"CMD-SHELL", cmd}
+		}
+	}
+	labels := map[string]string{}
+	for _, label := range context.StringSlice("label") {
+		parts := strings.SplitN(label, "=", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("Invalid label: %s", label)
+		}
+		labels[parts[0]] = ""
+		if len(parts) == 2 {
+			labels[parts[0]] = parts[1]
+		}
+	}
+	var ulimits []*container.Rlimit
+	for _, u := range context.StringSlice("ulimit") {
+		rlimit, err := container.ParseUlimit(u)
+		if err != nil {
+			return nil, err
+		}
+		ulimits = append(ulimits, rlimit)
+	}
+	workdir := context.String("w")
+	if workdir != "" && !filepath.IsAbs(workdir) {
+		return nil, fmt.Errorf("Working directory %s is not an absolute path", workdir)
+	}
+	stopSignal := context.String("stop-signal")
+	if stopSignal != "" {
+		if _, err := container.ParseSignal(stopSignal); err != nil {
+			return nil, err
+		}
+	}
+	var cmdArray []string
+	for _, arg := range context.Args() {
+		cmdArray = append(cmdArray, arg)
+	}
+	return &container.ContainerInfo{
+		Name:  context.String("name"),
+		Image: cmdArray[0],
+		Args:  cmdArray[1:],
+		Env:   context.StringSlice("e"),
+		Tty:   context.Bool("it"),
+		Resources: &subsystems.ResourceConfig{
+			MemoryLimit: context.String("m"),
+			CpuSet:      context.String("cpuset"),
+			CpuShare:    context.String("cpushare"),
+		},
+		Mounts:        mounts,
+		Network:       context.String("net"),
+		PortMapping:   context.StringSlice("p"),
+		ReadOnly:      context.Bool("read-only"),
+		Tmpfs:         context.StringSlice("tmpfs"),
+		StorageOpt:    storageOpt,
+		StopSignal:    stopSignal,
+		RestartPolicy: restartPolicy,
+		Healthcheck:   healthcheck,
+		WorkingDir:    workdir,
+		User:          context.String("u"),
+		Labels:        labels,
+		Ulimits:       ulimits,
+	}, nil
+}
+
 var runCommand = cli.Command{
 	Name: "run",
 	Usage: `Create a container with namespace and cgroup limit
 			mydocker run -it [command]`,
-	Flags: []cli.Flag{
-		cli.BoolFlag{
-			Name:  "it",
-			Usage: "enable tty",
-		},
-		cli.StringFlag{
-			Name:  "m",
-			Usage: "memory limit",
-		},
-		cli.StringFlag{
-			Name:  "cpuset",
-			Usage: "cpuset limit",
-		},
-		cli.StringFlag{
-			Name:  "cpushare",
-			Usage: "cpu share limit",
-		},
+	Flags: append([]cli.Flag{
 		cli.BoolFlag{
 			Name:  "d",
 			Usage: "detach container",
 		},
-		cli.StringFlag{
-			Name:  "name",
-			Usage: "container name",
-		},
-		cli.StringFlag{
-			Name:  "v",
-			Usage: "volume",
-		},
-		cli.StringSliceFlag{
-			Name:  "e",
-			Usage: "set environment variables",
-		},
-		cli.StringFlag{
-			Name:  "net",
-			Usage: "container network",
-		},
-		cli.StringSliceFlag{
-			Name:  "p",
-			Usage: "port mapping",
+		cli.BoolFlag{
+			Name:  "rm",
+			Usage: "remove the container when it exits",
 		},
+	}, containerFlags...),
+	Action: func(context *cli.Context) error {
+		containerInfo, err := newContainerInfo(context)
+		if err != nil {
+			return err
+		}
+		if containerInfo.Tty && context.Bool("d") {
+			return fmt.Errorf("-it and -d parameter can not both exist.")
+		}
+		if context.Bool("rm") && containerInfo.RestartPolicy.Name != container.RestartNo {
+			return fmt.Errorf("Conflicting options: --restart and --rm")
+		}
+		// tty containers are always removed when they exit
+		containerInfo.AutoRemove = context.Bool("rm") || containerInfo.Tty
+		return Run(containerInfo)
 	},
+}
+
+var createCommand = cli.Command{
+	Name: "create",
+	Usage: `Create a container without starting it
+			mydocker create [options] image [command]`,
+	Flags: containerFlags,
+	Action: func(context *cli.Context) error {
+		containerInfo, err := newContainerInfo(context)
+		if err != nil {
+			return err
+		}
+		if err := createContainer(containerInfo); err != nil {
+			return fmt.Errorf("Create container error: %v", err)
+		}
+		fmt.Println(containerInfo.Name)
+		return nil
+	},
+}
+
+var startCommand = cli.Command{
+	Name:   "start",
+	Usage:  "start created or stopped containers",
+	Before: reconcileBefore,
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
-			return fmt.Errorf("Missing container command")
-		}
-		tty := context.Bool("it")
-		memoryLimit := context.String("m")
-		cpuset := context.String("cpuset")
-		cpushare := context.String("cpushare")
-		detach := context.Bool("d")
-		containerName := context.String("name")
-		volume := context.String("v")
-		envSlice := context.StringSlice("e")
-		network := context.String("net")
-		portmapping := context.StringSlice("p")
-
-		if tty && detach {
-			return fmt.Errorf("-it and -d parameter can not both exist.")
+			return fmt.Errorf("Missing container name")
 		}
-		resConf := &subsystems.ResourceConfig{
-			MemoryLimit: memoryLimit,
-			CpuSet:      cpuset,
-			CpuShare:    cpushare,
+		failed := false
+		for _, containerName := range context.Args() {
+			containerName, err := resolveContainerName(containerName)
+			if err != nil {
+				log.Errorf("%v", err)
+				failed = true
+				continue
+			}
+			if err := startContainerByName(containerName); err != nil {
+				log.Errorf("Start container %s error: %v", containerName, err)
+				failed = true
+			}
 		}
-		var cmdArray []string
-		for _, arg := range context.Args() {
-			cmdArray = append(cmdArray, arg)
+		if failed {
+			return cli.NewExitError("", 1)
 		}
-		imageName := cmdArray[0]
-		cmdArray = cmdArray[1:]
-		Run(tty, cmdArray, resConf, volume, containerName, imageName, envSlice, network, portmapping)
 		return nil
 	},
 }
@@ -101,6 +302,18 @@ var initCommand = cli.Command{
 	},
 }
 
+var shimCommand = cli.Command{
+	Name:   "shim",
+	Usage:  "Monitor a detached container until it exits. Do not call it outside",
+	Hidden: true,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		return runShim(context.Args().Get(0))
+	},
+}
+
 var commitCommand = cli.Command{
 	Name:  "commit",
 	Usage: "commit a container into image",
@@ -115,21 +328,43 @@ var commitCommand = cli.Command{
 			return fmt.Errorf("Missing container name")
 		}
 		packageName := context.String("name")
-		containerName := context.Args().Get(0)
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		commitContainer(packageName, containerName)
 		return nil
 	},
 }
 
 var listCommand = cli.Command{
-	Name:  "ps",
-	Usage: "list all the containers",
+	Name:   "ps",
+	Usage:  "list all the containers",
+	Before: reconcileBefore,
 	Action: func(context *cli.Context) error {
 		ListContainers()
 		return nil
 	},
 }
 
+var inspectCommand = cli.Command{
+	Name:   "inspect",
+	Usage:  "show the configuration and state of containers",
+	Before: reconcileBefore,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "format the output with a Go template, e.g. '{{.State.ExitCode}}'",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		return inspectContainers(context.Args(), context.String("format"))
+	},
+}
+
 var logCommand = cli.Command{
 	Name:  "logs",
 	Usage: "print logs of a container",
@@ -137,15 +372,80 @@ var logCommand = cli.Command{
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Please input container name")
 		}
-		containerName := context.Args().Get(0)
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		logContainer(containerName)
 		return nil
 	},
 }
 
+var diffCommand = cli.Command{
+	Name:  "diff",
+	Usage: "show the filesystem changes of a container",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		return diffContainer(containerName)
+	},
+}
+
+var cpCommand = cli.Command{
+	Name: "cp",
+	Usage: `copy files between a container and the host
+			mydocker cp container:path hostpath|-
+			mydocker cp hostpath|- container:path`,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 2 {
+			return fmt.Errorf("Missing source or destination")
+		}
+		src := context.Args().Get(0)
+		dst := context.Args().Get(1)
+		if dst == "-" {
+			// stdout carries the tar stream
+			log.SetOutput(os.Stderr)
+		}
+		return copyContainer(src, dst)
+	},
+}
+
+var untarCommand = cli.Command{
+	Name:   "untar",
+	Usage:  "Extract a tar stream inside a container root. Do not call it outside",
+	Hidden: true,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 2 {
+			return fmt.Errorf("Missing root or directory")
+		}
+		args := context.Args()
+		return untarInRoot(args.Get(0), args.Get(1), args.Get(2), args.Get(3))
+	},
+}
+
+var tarCommand = cli.Command{
+	Name:   "tar",
+	Usage:  "Write a tar stream of a path inside a container root. Do not call it outside",
+	Hidden: true,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 2 {
+			return fmt.Errorf("Missing root or path")
+		}
+		// stdout carries the tar stream
+		log.SetOutput(os.Stderr)
+		return tarInRoot(context.Args().Get(0), context.Args().Get(1))
+	},
+}
+
 var execCommand = cli.Command{
-	Name:  "exec",
-	Usage: "exec a command into a container",
+	Name:   "exec",
+	Usage:  "exec a command into a container",
+	Before: reconcileBefore,
 	Action: func(context *cli.Context) error {
 		// The second time it gots here, C code has already been executed, thus return
 		if os.Getenv(ENV_EXEC_CMD) != "" {
@@ -155,7 +455,10 @@ var execCommand = cli.Command{
 		if len(context.Args()) < 2 {
 			return fmt.Errorf("Missing container name or command")
 		}
-		containerName := context.Args().Get(0)
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		var cmdArray []string
 		for _, arg := range context.Args().Tail() {
 			cmdArray = append(cmdArray, arg)
@@ -165,28 +468,259 @@ var execCommand = cli.Command{
 	},
 }
 
+var waitCommand = cli.Command{
+	Name:   "wait",
+	Usage:  "block until containers exit and print their exit codes",
+	Before: reconcileBefore,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		// fail if any container failed, with the last non-zero code
+		code := 0
+		for _, containerName := range context.Args() {
+			containerName, err := resolveContainerName(containerName)
+			if err != nil {
+				log.Errorf("%v", err)
+				code = 1
+				continue
+			}
+			exitCode, err := waitContainer(containerName)
+			if err != nil {
+				log.Errorf("Wait container %s error: %v", containerName, err)
+				code = 1
+				continue
+			}
+			fmt.Println(exitCode)
+			if exitCode != 0 {
+				code = exitCode
+			}
+		}
+		if code != 0 {
+			return cli.NewExitError("", code)
+		}
+		return nil
+	},
+}
+
 var stopCommand = cli.Command{
-	Name:  "stop",
-	Usage: "stop a container",
+	Name:   "stop",
+	Usage:  "stop containers, killing them if they do not exit in time",
+	Before: reconcileBefore,
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t",
+			Value: int(defaultStopTimeout / time.Second),
+			Usage: "seconds to wait before killing the container",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		timeout := time.Duration(context.Int("t")) * time.Second
+		failed := false
+		for _, containerName := range context.Args() {
+			containerName, err := resolveContainerName(containerName)
+			if err != nil {
+				log.Errorf("%v", err)
+				failed = true
+				continue
+			}
+			if err := stopContainer(containerName, timeout); err != nil {
+				log.Errorf("Stop container %s error: %v", containerName, err)
+				failed = true
+			}
+		}
+		if failed {
+			return cli.NewExitError("", 1)
+		}
+		return nil
+	},
+}
+
+var restartCommand = cli.Command{
+	Name:   "restart",
+	Usage:  "stop and start containers again",
+	Before: reconcileBefore,
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t",
+			Value: int(defaultStopTimeout / time.Second),
+			Usage: "seconds to wait before killing the container",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		timeout := time.Duration(context.Int("t")) * time.Second
+		failed := false
+		for _, containerName := range context.Args() {
+			containerName, err := resolveContainerName(containerName)
+			if err != nil {
+				log.Errorf("%v", err)
+				failed = true
+				continue
+			}
+			if err := restartContainer(containerName, timeout); err != nil {
+				log.Errorf("Restart container %s error: %v", containerName, err)
+				failed = true
+			}
+		}
+		if failed {
+			return cli.NewExitError("", 1)
+		}
+		return nil
+	},
+}
+
+var renameCommand = cli.Command{
+	Name:  "rename",
+	Usage: "rename a container, e.g. mydocker rename OLD NEW",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 2 {
+			return fmt.Errorf("Missing container name or new name")
+		}
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		return renameContainer(containerName, context.Args().Get(1))
+	},
+}
+
+var killCommand = cli.Command{
+	Name:   "kill",
+	Usage:  "send a signal to running containers",
+	Before: reconcileBefore,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "s",
+			Value: "KILL",
+			Usage: "signal name or number",
+		},
+		cli.BoolFlag{
+			Name:  "all",
+			Usage: "signal every process of the container, not only its init process",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Missing container name")
 		}
-		containerName := context.Args().Get(0)
-		stopContainer(containerName)
+		failed := false
+		for _, containerName := range context.Args() {
+			containerName, err := resolveContainerName(containerName)
+			if err != nil {
+				log.Errorf("%v", err)
+				failed = true
+				continue
+			}
+			if err := killContainer(containerName, context.String("s"), context.Bool("all")); err != nil {
+				log.Errorf("Kill container %s error: %v", containerName, err)
+				failed = true
+			}
+		}
+		if failed {
+			return cli.NewExitError("", 1)
+		}
+		return nil
+	},
+}
+
+var pauseCommand = cli.Command{
+	Name:   "pause",
+	Usage:  "freeze all processes of containers",
+	Before: reconcileBefore,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		failed := false
+		for _, containerName := range context.Args() {
+			containerName, err := resolveContainerName(containerName)
+			if err != nil {
+				log.Errorf("%v", err)
+				failed = true
+				continue
+			}
+			if err := pauseContainer(containerName); err != nil {
+				log.Errorf("Pause container %s error: %v", containerName, err)
+				failed = true
+			}
+		}
+		if failed {
+			return cli.NewExitError("", 1)
+		}
+		return nil
+	},
+}
+
+var unpauseCommand = cli.Command{
+	Name:   "unpause",
+	Usage:  "thaw paused containers",
+	Before: reconcileBefore,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		failed := false
+		for _, containerName := range context.Args() {
+			containerName, err := resolveContainerName(containerName)
+			if err != nil {
+				log.Errorf("%v", err)
+				failed = true
+				continue
+			}
+			if err := unpauseContainer(containerName); err != nil {
+				log.Errorf("Unpause container %s error: %v", containerName, err)
+				failed = true
+			}
+		}
+		if failed {
+			return cli.NewExitError("", 1)
+		}
 		return nil
 	},
 }
 
 var removeCommand = cli.Command{
-	Name:  "rm",
-	Usage: "remove a container",
+	Name:   "rm",
+	Usage:  "remove containers",
+	Before: reconcileBefore,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "f",
+			Usage: "kill and remove running containers",
+		},
+		cli.BoolFlag{
+			Name:  "v",
+			Usage: "remove the anonymous volumes of the containers",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Missing container name")
 		}
-		containerName := context.Args().Get(0)
-		removeContainer(containerName)
+		failed := false
+		for _, containerName := range context.Args() {
+			containerName, err := resolveContainerName(containerName)
+			if err != nil {
+				log.Errorf("%v", err)
+				failed = true
+				continue
+			}
+			if err := removeContainer(containerName, context.Bool("f"), context.Bool("v")); err != nil {
+				log.Errorf("Remove container %s error: %v", containerName, err)
+				failed = true
+				continue
+			}
+			fmt.Println(containerName)
+		}
+		if failed {
+			return cli.NewExitError("", 1)
+		}
 		return nil
 	},
 }
@@ -249,3 +783,155 @@ var networkCommand = cli.Command{
 		},
 	},
 }
+
+var volumeCommand = cli.Command{
+	Name:  "volume",
+	Usage: "container volume commands",
+	Subcommands: []cli.Command{
+		{
+			Name:  "create",
+			Usage: "create a volume",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "driver",
+					Usage: "Driver to manage the volume",
+				},
+				cli.StringSliceFlag{
+					Name:  "opt",
+					Usage: "Driver specific options, key=value",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("Missing volume name")
+				}
+				opts := map[string]string{}
+				for _, opt := range context.StringSlice("opt") {
+					kv := strings.SplitN(opt, "=", 2)
+					if len(kv) != 2 {
+						return fmt.Errorf("Invalid volume option: %s", opt)
+					}
+					opts[kv[0]] = kv[1]
+				}
+				if err := volume.Init(); err != nil {
+					return err
+				}
+				if err := volume.CreateVolume(context.String("driver"), context.Args().Get(0), opts); err != nil {
+					return fmt.Errorf("Create volume error: %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "list volumes",
+			Action: func(context *cli.Context) error {
+				if err := volume.Init(); err != nil {
+					return err
+				}
+				volume.ListVolume()
+				return nil
+			},
+		},
+		{
+			Name:  "remove",
+			Usage: "remove a volume",
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("Missing volume name")
+				}
+				name := context.Args().Get(0)
+				if err := volume.Init(); err != nil {
+					return err
+				}
+				if user := volumeUser(name, ""); user != "" {
+					return fmt.Errorf("Remove volume error: volume %s is in use by container %s", name, user)
+				}
+				if err := volume.RemoveVolume(name); err != nil {
+					return fmt.Errorf("Remove volume error: %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "export",
+			Usage: "export a volume as a tar archive, mydocker volume export [-o file] name",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "o",
+					Value: "-",
+					Usage: "Write to a file instead of stdout",
+				},
+				cli.BoolFlag{
+					Name:  "pause",
+					Usage: "Pause the containers using the volume during export",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("Missing volume name")
+				}
+				if context.String("o") == "-" {
+					// stdout carries the tar stream
+					log.SetOutput(os.Stderr)
+				}
+				if err := exportVolume(context.Args().Get(0), context.String("o"), context.Bool("pause")); err != nil {
+					return fmt.Errorf("Export volume error: %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "import",
+			Usage: "restore a volume from a tar archive, mydocker volume import name [file|-]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "driver",
+					Usage: "Driver of the volume if it has to be created",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("Missing volume name")
+				}
+				input := "-"
+				if len(context.Args()) > 1 {
+					input = context.Args().Get(1)
+				}
+				if err := importVolume(context.String("driver"), context.Args().Get(0), input); err != nil {
+					return fmt.Errorf("Import volume error: %v", err)
+				}
+				return nil
+			},
+		},
+	},
+}
+
+var systemCommand = cli.Command{
+	Name:  "system",
+	Usage: "manage mydocker",
+	Subcommands: []cli.Command{
+		{
+			Name:  "df",
+			Usage: "show disk usage of images, containers, volumes and logs",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "v",
+					Usage: "show per object usage",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				return systemDiskUsage(context.Bool("v"))
+			},
+		},
+		{
+			Name:   "restore",
+			Usage:  "bring back containers with restart policy always or unless-stopped, run it on boot",
+			Before: reconcileBefore,
+			Action: func(context *cli.Context) error {
+				restoreContainers()
+				return nil
+			},
+		},
+	},
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"github.com/seagullbird/mydocker/container"
+	"github.com/seagullbird/mydocker/volume"
+	"sort"
+	"strings"
+)
+
+// parseMounts builds the mount list of a new container from -v specs and
+// --volumes-from container[:ro|rw] references. Explicit volumes win over
+// inherited ones with the same destination.
+func parseMounts(volumeSpecs []string, volumeDriver string, volumesFrom []string) ([]*container.Mount, error) {
+	var mounts []*container.Mount
+	destinations := map[string]bool{}
+	for _, spec := range volumeSpecs {
+		m, err := volume.ParseMount(spec, volumeDriver)
+		if err != nil {
+			return nil, err
+		}
+		if destinations[m.Destination] {
+			return nil, fmt.Errorf("Duplicate mount point: %s", m.Destination)
+		}
+		destinations[m.Destination] = true
+		mounts = append(mounts, m)
+	}
+
+	for _, from := range volumesFrom {
+		containerName, mode := from, ""
+		if i := strings.LastIndex(from, ":"); i != -1 {
+			containerName, mode = from[:i], from[i+1:]
+		}
+		if mode != "" && mode != "ro" && mode != "rw" {
+			return nil, fmt.Errorf("Invalid mode %s in --volumes-from %s", mode, from)
+		}
+		containerName, err := resolveContainerName(containerName)
+		if err != nil {
+			return nil, err
+		}
+		containerInfo, err := GetContainerInfoByName(containerName)
+		if err != nil {
+			return nil, fmt.Errorf("Get container %s info error: %v", containerName, err)
+		}
+		for _, src := range containerInfo.Mounts {
+			if destinations[src.Destination] {
+				continue
+			}
+			m := *src
+			// the volume stays with the container which created it
+			m.Anonymous = false
+			switch mode {
+			case "ro":
+				m.RW = false
+			case "rw":
+				m.RW = true
+			}
+			destinations[m.Destination] = true
+			mounts = append(mounts, &m)
+		}
+	}
+
+	// parents are mounted before what is mounted inside them
+	sort.Slice(mounts, func(i, j int) bool {
+		return mounts[i].Destination < mounts[j].Destination
+	})
+	return mounts, nil
+}
+
+// legacyMount turns the hostpath:containerpath or name:containerpath spec
+// recorded before Mounts into the mount it was attached as
+func legacyMount(spec, volumeDriver string) (*container.Mount, error) {
+	m, err := volume.ParseMount(spec, volumeDriver)
+	if err != nil {
+		return nil, err
+	}
+	if m.Type == container.MountTypeBind {
+		return m, nil
+	}
+	if m.Anonymous {
+		return nil, fmt.Errorf("Volume parameter input is not correct: %s", spec)
+	}
+	if err := volume.Init(); err != nil {
+		return nil, err
+	}
+	if err := volume.Locate(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
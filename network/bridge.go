@@ -70,8 +70,14 @@ func (d *BridgeNetworkDriver) Connect(network *Network, endpoint *Endpoint) erro
 	return nil
 }
 
+// Disconnect deletes the host end of the veth, which only outlives the
+// container when its network namespace is still held by some process
 func (d *BridgeNetworkDriver) Disconnect(network *Network, endpoint *Endpoint) error {
-	return nil
+	veth, err := netlink.LinkByName(endpoint.ID[:5])
+	if err != nil {
+		return nil
+	}
+	return netlink.LinkDel(veth)
 }
 
 func (d *BridgeNetworkDriver) initBridge(nw *Network) error {
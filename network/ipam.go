@@ -10,16 +10,13 @@ import (
 	"strings"
 )
 
-var ipamDefaultAllocatorPath = filepath.Join(networkRootDir, "ipam", "subnet.json")
-
 type IPAM struct {
 	subnetAllocatorPath string
 	Subnets             *map[string]string
 }
 
-var ipAllocator = &IPAM{
-	subnetAllocatorPath: ipamDefaultAllocatorPath,
-}
+// subnetAllocatorPath is set by SetRootDir
+var ipAllocator = &IPAM{}
 
 func (ipam *IPAM) load() error {
 	if _, err := os.Stat(ipam.subnetAllocatorPath); err != nil {
@@ -30,12 +30,23 @@ type Network struct {
 }
 
 var (
-	networkRootDir = filepath.Join(container.RootDir, "network")
-	networkInfoDir = filepath.Join(networkRootDir, "networks")
+	networkRootDir string
+	networkInfoDir string
 	drivers        = map[string]NetworkDriver{}
 	networks       = map[string]*Network{}
 )
 
+func init() {
+	SetRootDir(container.RootDir)
+}
+
+// SetRootDir relocates network and ipam state under root
+func SetRootDir(root string) {
+	networkRootDir = filepath.Join(root, "network")
+	networkInfoDir = filepath.Join(networkRootDir, "networks")
+	ipAllocator.subnetAllocatorPath = filepath.Join(networkRootDir, "ipam", "subnet.json")
+}
+
 type Endpoint struct {
 	ID          string           `json:"id"`
 	Device      netlink.Veth     `json:"dev"`
@@ -139,17 +150,48 @@ func (nw *Network) remove(dumpPath string) error {
 	return os.Remove(filepath.Join(dumpPath, nw.Name))
 }
 
-func Connect(networkName string, cinfo *container.ContainerInfo) (net.IP, error) {
+// Reserve leases an ip address of the network for a container
+// which is connected later
+func Reserve(networkName string, cinfo *container.ContainerInfo) (net.IP, error) {
 	nw, ok := networks[networkName]
 	if !ok {
 		return nil, fmt.Errorf("No such Network: %s", networkName)
 	}
-	// get ip address for the container
 	ip, err := ipAllocator.Allocate(nw.IpRange)
 	if err != nil {
 		return nil, err
 	}
 	cinfo.IPAddress = ip
+	return ip, nil
+}
+
+// Release gives back the ip address leased by Reserve
+func Release(networkName string, cinfo *container.ContainerInfo) error {
+	nw, ok := networks[networkName]
+	if !ok {
+		return fmt.Errorf("No such Network: %s", networkName)
+	}
+	if err := ipAllocator.Release(nw.IpRange, cinfo.IPAddress); err != nil {
+		return err
+	}
+	cinfo.IPAddress = nil
+	return nil
+}
+
+// Connect plugs a running container into the network, keeping the ip
+// address it has already been leased
+func Connect(networkName string, cinfo *container.ContainerInfo) (net.IP, error) {
+	nw, ok := networks[networkName]
+	if !ok {
+		return nil, fmt.Errorf("No such Network: %s", networkName)
+	}
+	// get ip address for the container
+	if cinfo.IPAddress == nil {
+		if _, err := Reserve(networkName, cinfo); err != nil {
+			return nil, err
+		}
+	}
+	ip := cinfo.IPAddress
 	// create network endpoint
 	ep := &Endpoint{
 		ID:          fmt.Sprintf("%s-%s", cinfo.Id, networkName),
@@ -158,11 +200,11 @@ func Connect(networkName string, cinfo *container.ContainerInfo) (net.IP, error)
 		PortMapping: cinfo.PortMapping,
 	}
 	// deal with the end connecting the bridge
-	if err = drivers[nw.Driver].Connect(nw, ep); err != nil {
+	if err := drivers[nw.Driver].Connect(nw, ep); err != nil {
 		return nil, err
 	}
 	// deal with the end connecting the container
-	if err = configEndpointIpAddressAndRoute(ep, cinfo); err != nil {
+	if err := configEndpointIpAddressAndRoute(ep, cinfo); err != nil {
 		return nil, err
 	}
 
@@ -174,6 +216,13 @@ func Disconnect(networkName string, cinfo *container.ContainerInfo) error {
 	if !ok {
 		return fmt.Errorf("No such Network: %s", networkName)
 	}
+	ep := &Endpoint{
+		ID:      fmt.Sprintf("%s-%s", cinfo.Id, networkName),
+		Network: nw,
+	}
+	if err := drivers[nw.Driver].Disconnect(nw, ep); err != nil {
+		log.Errorf("Delete endpoint %s error: %v", ep.ID, err)
+	}
 	// release ip address
 	ipAllocator.Release(nw.IpRange, cinfo.IPAddress)
 	// delete DNAT
@@ -216,6 +265,15 @@ func Init() error {
 	return nil
 }
 
+// GetNetwork returns a network loaded by Init
+func GetNetwork(networkName string) (*Network, error) {
+	nw, ok := networks[networkName]
+	if !ok {
+		return nil, fmt.Errorf("No such Network: %s", networkName)
+	}
+	return nw, nil
+}
+
 func ListNetwork() {
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
 	fmt.Fprint(w, "NAME\tIpRange\tDriver\n")
@@ -301,7 +359,7 @@ func configEndpointIpAddressAndRoute(ep *Endpoint, cinfo *container.ContainerInf
 
 func enterContainerNetns(enLink *netlink.Link, cinfo *container.ContainerInfo) func() {
 	// find container net namespace
-	f, err := os.OpenFile(fmt.Sprintf("/proc/%s/ns/net", cinfo.Pid), os.O_RDONLY, 0)
+	f, err := os.OpenFile(fmt.Sprintf("/proc/%d/ns/net", cinfo.Pid), os.O_RDONLY, 0)
 	if err != nil {
 		log.Errorf("error get container net namespace, %v", err)
 	}
@@ -341,9 +399,13 @@ func configPortMapping(ep *Endpoint, cinfo *container.ContainerInfo) error {
 			log.Errorf("port mapping format error, %v", pm)
 			continue
 		}
-		iptablesCmd := fmt.Sprintf("-t nat -A PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
+		rule := fmt.Sprintf("PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
 			portMapping[0], ep.IPAddress.String(), portMapping[1])
-		cmd := exec.Command("iptables", strings.Split(iptablesCmd, " ")...)
+		// the rule survives when a container is stopped and started again
+		if exec.Command("iptables", strings.Split("-t nat -C "+rule, " ")...).Run() == nil {
+			continue
+		}
+		cmd := exec.Command("iptables", strings.Split("-t nat -A "+rule, " ")...)
 		output, err := cmd.Output()
 		if err != nil {
 			log.Errorf("iptables Output, %v", output)
@@ -7,6 +7,7 @@ package nsenter
 #include <stdlib.h>
 #include <string.h>
 #include <fcntl.h>
+#include <sys/wait.h>
 
 __attribute__((constructor)) void enter_namespace(void) {
 	char *mydocker_pid;
@@ -41,7 +42,11 @@ __attribute__((constructor)) void enter_namespace(void) {
 		close(fd);
 	}
 	int res = system(mydocker_cmd);
-	exit(0);
+	// pass on the exit status of the command
+	if (res != -1 && WIFEXITED(res)) {
+		exit(WEXITSTATUS(res));
+	}
+	exit(1);
 	return;
 }
 */
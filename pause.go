@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+)
+
+// pauseContainer freezes every process of a running container
+func pauseContainer(containerName string) error {
+	containerInfo, err := GetContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("Get container %s info error: %v", containerName, err)
+	}
+	if containerInfo.Status != container.RUNNING {
+		return fmt.Errorf("Container %s is not running", containerName)
+	}
+	if err := containerCgroupManager(containerInfo).Freeze(); err != nil {
+		return fmt.Errorf("Freeze container %s error: %v", containerName, err)
+	}
+	if err := setContainerStatus(containerName, container.RUNNING, container.PAUSED); err != nil {
+		return fmt.Errorf("Record container %s info error: %v", containerName, err)
+	}
+	log.Infof("Container %s paused", containerName)
+	return nil
+}
+
+// unpauseContainer thaws a paused container
+func unpauseContainer(containerName string) error {
+	containerInfo, err := GetContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("Get container %s info error: %v", containerName, err)
+	}
+	if containerInfo.Status != container.PAUSED {
+		return fmt.Errorf("Container %s is not paused", containerName)
+	}
+	if err := containerCgroupManager(containerInfo).Thaw(); err != nil {
+		return fmt.Errorf("Thaw container %s error: %v", containerName, err)
+	}
+	if err := setContainerStatus(containerName, container.PAUSED, container.RUNNING); err != nil {
+		return fmt.Errorf("Record container %s info error: %v", containerName, err)
+	}
+	log.Infof("Container %s unpaused", containerName)
+	return nil
+}
+
+// setContainerStatus moves a container from status from to status to,
+// unless its monitor has recorded an exit meanwhile
+func setContainerStatus(containerName, from, to string) error {
+	return updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status != from {
+			return fmt.Errorf("Container %s is %s", containerName, containerInfo.Status)
+		}
+		containerInfo.Status = to
+		return nil
+	})
+}
@@ -4,10 +4,15 @@ if [ ! -n "$1" ]; then
 	exit 1
 fi
 
+ROOT=${MYDOCKER_ROOT:-/var/lib/mydocker}
+
 docker pull $1
 IN=$1
 image_name=(${IN//:/ })
 docker export $(docker create ${image_name}) > /tmp/${image_name}.tar
-rm -rf /var/lib/mydocker/overlay2/${image_name}
-mkdir /var/lib/mydocker/overlay2/${image_name}
-tar -xf /tmp/${image_name}.tar -C /var/lib/mydocker/overlay2/${image_name}
+rm -rf ${ROOT}/overlay2/${image_name}
+mkdir -p ${ROOT}/overlay2/${image_name}
+tar -xf /tmp/${image_name}.tar -C ${ROOT}/overlay2/${image_name}
+# runtime defaults such as StopSignal
+mkdir -p ${ROOT}/images
+docker inspect -f '{{json .Config}}' $1 > ${ROOT}/images/${image_name}.json
@@ -0,0 +1,80 @@
+package main
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+	"github.com/seagullbird/mydocker/network"
+	"github.com/urfave/cli"
+	"time"
+)
+
+// exit code of a container whose exit nobody saw
+const unknownExitCode = 255
+
+// initAlive tells whether the init process of a container still runs, its
+// pid may belong to an unrelated process after a crash or a reboot
+func initAlive(containerInfo *container.ContainerInfo) bool {
+	return container.IsProcessInstanceAlive(containerInfo.Pid, containerInfo.PidStartTime)
+}
+
+func monitorAlive(containerInfo *container.ContainerInfo) bool {
+	return container.IsProcessInstanceAlive(containerInfo.MonitorPid, containerInfo.MonitorStartTime)
+}
+
+// isStale tells whether a container is recorded as running or restarting
+// while its processes have died unseen
+func isStale(containerInfo *container.ContainerInfo) bool {
+	switch containerInfo.Status {
+	case container.RUNNING, container.PAUSED:
+		// the monitor records the exit of the init process
+		return !monitorAlive(containerInfo) && !initAlive(containerInfo)
+	case container.RESTARTING:
+		return !monitorAlive(containerInfo)
+	}
+	return false
+}
+
+// reconcileContainers marks the stale containers exited
+func reconcileContainers() {
+	for _, containerInfo := range loadContainerInfos() {
+		if !isStale(containerInfo) {
+			continue
+		}
+		if err := reconcileContainer(containerInfo.Name); err != nil {
+			log.Errorf("Reconcile container %s error: %v", containerInfo.Name, err)
+		}
+	}
+}
+
+// reconcileBefore is the Before of the commands reading container states
+func reconcileBefore(context *cli.Context) error {
+	reconcileContainers()
+	return nil
+}
+
+// reconcileContainer marks a stale container exited and releases what its
+// processes held: mounts, cgroup, veth, port mappings and ip address
+func reconcileContainer(containerName string) error {
+	return updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if !isStale(containerInfo) {
+			return nil
+		}
+		log.Warnf("Container %s has died unseen, marking it exited", containerName)
+		if containerInfo.Network != "" && containerInfo.IPAddress != nil {
+			network.Init()
+			if err := network.Disconnect(containerInfo.Network, containerInfo); err != nil {
+				log.Errorf("Disconnect container %s error: %v", containerName, err)
+			}
+			containerInfo.IPAddress = nil
+		}
+		if err := container.UnmountWorkSpace(containerInfo.Mounts, containerName); err != nil {
+			log.Errorf("Unmount workspace of container %s error: %v", containerName, err)
+		}
+		containerCgroupManager(containerInfo).Destroy()
+		containerInfo.Status = container.Exit
+		containerInfo.Pid = 0
+		containerInfo.ExitCode = unknownExitCode
+		containerInfo.FinishedAt = time.Now().Format(time.RFC3339Nano)
+		return nil
+	})
+}
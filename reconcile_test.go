@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/seagullbird/mydocker/container"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// deadPid returns the pid of a process which has exited
+func deadPid(t *testing.T) int {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestIsStale(t *testing.T) {
+	live := os.Getpid()
+	liveStart, err := container.ProcessStartTime(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := deadPid(t)
+	// a pid reused by another process, it started at another time
+	reusedStart := liveStart - 1
+
+	cases := []struct {
+		name         string
+		status       string
+		monitor      int
+		monitorStart uint64
+		pid          int
+		pidStart     uint64
+		want         bool
+	}{
+		{"running", container.RUNNING, live, liveStart, live, liveStart, false},
+		{"running without monitor", container.RUNNING, dead, 0, live, liveStart, false},
+		{"running with its init dead", container.RUNNING, live, liveStart, dead, 0, false},
+		{"running dead", container.RUNNING, dead, 0, dead, 0, true},
+		{"running on reused pids", container.RUNNING, live, reusedStart, live, reusedStart, true},
+		{"running on a reused init pid", container.RUNNING, dead, 0, live, reusedStart, true},
+		{"paused", container.PAUSED, live, liveStart, live, liveStart, false},
+		{"paused dead", container.PAUSED, dead, 0, dead, 0, true},
+		{"paused on reused pids", container.PAUSED, live, reusedStart, live, reusedStart, true},
+		// no init runs between two restarts
+		{"restarting", container.RESTARTING, live, liveStart, 0, 0, false},
+		{"restarting dead", container.RESTARTING, dead, 0, 0, 0, true},
+		{"restarting on a reused monitor pid", container.RESTARTING, live, reusedStart, 0, 0, true},
+		{"exited", container.Exit, dead, 0, dead, 0, false},
+		{"stopped", container.STOP, dead, 0, dead, 0, false},
+		{"created", container.CREATED, 0, 0, 0, 0, false},
+	}
+	for _, c := range cases {
+		info := &container.ContainerInfo{
+			Status:           c.status,
+			MonitorPid:       c.monitor,
+			MonitorStartTime: c.monitorStart,
+			Pid:              c.pid,
+			PidStartTime:     c.pidStart,
+		}
+		if got := isStale(info); got != c.want {
+			t.Errorf("%s: isStale = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestReconcileContainer(t *testing.T) {
+	defer setTempRoot(t)()
+	live := os.Getpid()
+	liveStart, err := container.ProcessStartTime(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addContainer(t, "1111", "reused")
+	addContainer(t, "2222", "alive")
+	for name, startTime := range map[string]uint64{"reused": liveStart - 1, "alive": liveStart} {
+		err := updateContainerInfo(name, func(info *container.ContainerInfo) error {
+			info.Status = container.RUNNING
+			info.Pid, info.PidStartTime = live, startTime
+			info.MonitorPid, info.MonitorStartTime = live, startTime
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconcileContainers()
+	info, err := GetContainerInfoByName("reused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != container.Exit || info.Pid != 0 || info.ExitCode != unknownExitCode || info.FinishedAt == "" {
+		t.Errorf("container on reused pids = status %s pid %d exit code %d finished %q, want exited", info.Status, info.Pid, info.ExitCode, info.FinishedAt)
+	}
+	if info, err = GetContainerInfoByName("alive"); err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != container.RUNNING || info.Pid != live {
+		t.Errorf("live container = status %s pid %d, want running %d", info.Status, info.Pid, live)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"github.com/seagullbird/mydocker/container"
+	"os"
+	"syscall"
+)
+
+// renameContainer moves the info directory and write layer of a container
+// to a new name, it may be running meanwhile
+func renameContainer(containerName, newName string) error {
+	if containerName == newName {
+		return fmt.Errorf("Renaming a container with the same name as its current name")
+	}
+	if err := reserveContainerName(newName); err != nil {
+		return err
+	}
+	newInfoDir := fmt.Sprintf(container.DefaultInfoLocation, newName)
+	// the lock moves along with the info directory, so commands and the
+	// monitor holding it carry on under the new name
+	err := updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if err := container.RenameWorkSpace(containerName, newName); err != nil {
+			return fmt.Errorf("Rename workspace of container %s error: %v", containerName, err)
+		}
+		// replaces the empty reserved directory, which os.Rename refuses to
+		if err := syscall.Rename(fmt.Sprintf(container.DefaultInfoLocation, containerName), newInfoDir); err != nil {
+			container.RenameWorkSpace(newName, containerName)
+			return fmt.Errorf("Rename container %s error: %v", containerName, err)
+		}
+		containerInfo.Name = newName
+		return nil
+	})
+	if err != nil {
+		os.Remove(newInfoDir)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"github.com/seagullbird/mydocker/container"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setTempRoot points the state of mydocker to a temp dir for the test
+func setTempRoot(t *testing.T) func() {
+	root, err := ioutil.TempDir("", "mydocker-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldRoot := container.RootDir
+	container.SetRootDir(root)
+	return func() {
+		container.SetRootDir(oldRoot)
+		os.RemoveAll(root)
+	}
+}
+
+// addContainer records a stopped container with a write layer
+func addContainer(t *testing.T, id, name string) {
+	if err := reserveContainerName(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(container.ContainerWriteLayerPath(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	info := &container.ContainerInfo{Id: id, Name: name, Status: container.Exit}
+	if err := recordContainerInfo(info); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReserveContainerName(t *testing.T) {
+	defer setTempRoot(t)()
+	if err := reserveContainerName("web"); err != nil {
+		t.Fatalf("reserveContainerName(web) error: %v", err)
+	}
+	if err := reserveContainerName("web"); err == nil {
+		t.Error("reserving web twice should conflict")
+	}
+	if err := reserveContainerName("web.2"); err != nil {
+		t.Errorf("reserveContainerName(web.2) error: %v", err)
+	}
+	for _, name := range []string{"", "-web", "web/db", "web db", "../web"} {
+		if err := reserveContainerName(name); err == nil {
+			t.Errorf("reserveContainerName(%q) should fail", name)
+		}
+	}
+}
+
+func TestRenameContainer(t *testing.T) {
+	defer setTempRoot(t)()
+	addContainer(t, "1111", "old")
+	addContainer(t, "2222", "taken")
+	ioutil.WriteFile(filepath.Join(container.ContainerWriteLayerPath("old"), "file"), []byte("data"), 0644)
+
+	if err := renameContainer("old", "old"); err == nil {
+		t.Error("renaming to the same name should fail")
+	}
+	if err := renameContainer("old", "taken"); err == nil {
+		t.Error("renaming to a name in use should fail")
+	}
+	if info, err := GetContainerInfoByName("taken"); err != nil || info.Id != "2222" {
+		t.Errorf("container taken changed by a failed rename: %+v %v", info, err)
+	}
+
+	if err := renameContainer("old", "new"); err != nil {
+		t.Fatalf("renameContainer error: %v", err)
+	}
+	info, err := GetContainerInfoByName("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != "1111" || info.Name != "new" {
+		t.Errorf("renamed container is %s named %s, want 1111 named new", info.Id, info.Name)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(container.ContainerWriteLayerPath("new"), "file")); err != nil || string(content) != "data" {
+		t.Errorf("write layer not moved: %q %v", content, err)
+	}
+	for _, path := range []string{fmt.Sprintf(container.DefaultInfoLocation, "old"), container.ContainerWriteLayerPath("old")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after rename", path)
+		}
+	}
+	// the old name is free again
+	if err := reserveContainerName("old"); err != nil {
+		t.Errorf("reserveContainerName(old) after rename error: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+)
+
+// restoreContainers starts again the containers whose restart policy
+// brings them back after mydocker or the host restarted and which have
+// lost their monitor
+func restoreContainers() {
+	for _, containerInfo := range loadContainerInfos() {
+		if containerInfo.Status == container.CREATED || !containerInfo.RestartPolicy.RestartOnBoot(containerInfo.ManuallyStopped) {
+			continue
+		}
+		if monitorAlive(containerInfo) {
+			continue
+		}
+		if containerInfo.Tty {
+			// it would hold the loop in the foreground with no terminal to run on
+			log.Warnf("Container %s runs on a terminal, not restoring it", containerInfo.Name)
+			continue
+		}
+		if initAlive(containerInfo) {
+			log.Warnf("Container %s is running without monitor, leaving it alone", containerInfo.Name)
+			continue
+		}
+		containerInfo.Status = container.Exit
+		containerInfo.Pid = 0
+		log.Infof("Restoring container %s", containerInfo.Name)
+		if err := startContainer(containerInfo); err != nil {
+			log.Errorf("Restore container %s error: %v", containerInfo.Name, err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+// rollback collects the undo actions of the steps done so far, so that a
+// failing sequence of steps is unwound, the last step first
+type rollback struct {
+	undos []func()
+}
+
+// add registers how to undo a step, before the step is taken when it may
+// fail halfway
+func (r *rollback) add(undo func()) {
+	r.undos = append(r.undos, undo)
+}
+
+func (r *rollback) run() {
+	for i := len(r.undos) - 1; i >= 0; i-- {
+		r.undos[i]()
+	}
+	r.undos = nil
+}
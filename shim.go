@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+)
+
+// sent by the shim once the container has been launched
+const shimReady = "ready"
+
+// runShim is the long-lived monitor of a detached container. It is the
+// parent of the container init process, so it is the one reaping it and
+// recording its exit. Whether the container could be launched is reported
+// on fd 3 to the process which started the shim.
+func runShim(containerName string) error {
+	readyPipe := os.NewFile(uintptr(3), "ready")
+	ready := func(err error) {
+		if err != nil {
+			readyPipe.WriteString(err.Error())
+		} else {
+			readyPipe.WriteString(shimReady)
+		}
+		readyPipe.Close()
+	}
+	containerInfo, err := GetContainerInfoByName(containerName)
+	if err != nil {
+		ready(err)
+		return err
+	}
+	return monitorContainer(containerInfo, ready)
+}
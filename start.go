@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/cgroups"
+	"github.com/seagullbird/mydocker/cgroups/subsystems"
+	"github.com/seagullbird/mydocker/container"
+	"github.com/seagullbird/mydocker/network"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+	"time"
+)
+
+func startContainerByName(containerName string) error {
+	containerInfo, err := GetContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("Get container %s info error: %v", containerName, err)
+	}
+	if containerInfo.Status == container.RUNNING || containerInfo.Status == container.PAUSED {
+		return fmt.Errorf("Container %s is already running", containerName)
+	}
+	if containerInfo.Status == container.RESTARTING {
+		return fmt.Errorf("Container %s is restarting, stop it first", containerName)
+	}
+	return startContainer(containerInfo)
+}
+
+// startContainer runs a created or stopped container on its existing
+// workspace. A tty container runs in the foreground and this process is its
+// monitor, a detached container gets a shim process as monitor.
+func startContainer(containerInfo *container.ContainerInfo) error {
+	containerInfo.ManuallyStopped = false
+	containerInfo.RestartCount = 0
+	if err := recordContainerInfo(containerInfo); err != nil {
+		return err
+	}
+	if containerInfo.Tty {
+		return monitorContainer(containerInfo, func(error) {})
+	}
+	return startShim(containerInfo)
+}
+
+// monitorContainer launches the container, reports to ready whether it
+// started, then waits for it to exit and records how it exited. The
+// container is restarted as long as its restart policy says so, and
+// removed afterwards when it was run with --rm.
+func monitorContainer(containerInfo *container.ContainerInfo, ready func(error)) error {
+	cgroupManager := containerCgroupManager(containerInfo)
+	parent, err := launchContainer(containerInfo, cgroupManager)
+	ready(err)
+	if err != nil {
+		return err
+	}
+	var backoff time.Duration
+	for parent != nil {
+		startedAt := time.Now()
+		// the cgroup is reused across restarts, only count new kills
+		oomKills, _ := cgroupManager.OOMKillCount()
+		healthDone := startHealthcheck(containerInfo.Id, containerInfo.Healthcheck, parent.Process.Pid)
+		parent.Wait()
+		close(healthDone)
+		oomKilled := false
+		if n, err := cgroupManager.OOMKillCount(); err == nil && n > oomKills {
+			oomKilled = true
+		}
+		if err := recordContainerExit(containerInfo.Id, parent.ProcessState, oomKilled); err != nil {
+			return err
+		}
+		backoff = container.RestartBackoff(backoff, time.Since(startedAt))
+		if parent, err = relaunchContainer(containerInfo.Id, backoff, cgroupManager); err != nil {
+			return err
+		}
+	}
+	if info, err := getContainerInfoByID(containerInfo.Id); err == nil && info.AutoRemove {
+		log.Infof("Removing container %s", info.Name)
+		if err := destroyContainer(info); err != nil {
+			return err
+		}
+		removeAnonymousVolumes(info)
+	}
+	return nil
+}
+
+// relaunchContainer starts a restarting container again after backoff,
+// unless it has been stopped meanwhile
+func relaunchContainer(id string, backoff time.Duration, cgroupManager *cgroups.CgroupManager) (*exec.Cmd, error) {
+	containerInfo, err := getContainerInfoByID(id)
+	if err != nil || containerInfo.Status != container.RESTARTING {
+		return nil, err
+	}
+	log.Infof("Restarting container %s in %v", containerInfo.Name, backoff)
+	time.Sleep(backoff)
+	var parent *exec.Cmd
+	// stop waits for the lock, it sees the container either restarting or running
+	err = updateContainerInfoByID(id, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status != container.RESTARTING {
+			return nil
+		}
+		containerInfo.RestartCount++
+		p, err := launchContainer(containerInfo, cgroupManager)
+		if err != nil {
+			log.Errorf("Restart container %s error: %v", containerInfo.Name, err)
+			containerInfo.Status = container.Exit
+			containerInfo.Pid = 0
+			return nil
+		}
+		parent = p
+		return nil
+	})
+	return parent, err
+}
+
+// launchContainer starts the container process on its workspace, any step
+// failing undoes the ones done before
+func launchContainer(containerInfo *container.ContainerInfo, cgroupManager *cgroups.CgroupManager) (_ *exec.Cmd, err error) {
+	var undo rollback
+	defer func() {
+		if err != nil {
+			undo.run()
+		}
+	}()
+	saved := *containerInfo
+	undo.add(func() {
+		// the ip address has been given back along with the endpoint
+		ip := containerInfo.IPAddress
+		*containerInfo = saved
+		containerInfo.IPAddress = ip
+		recordContainerInfo(containerInfo)
+	})
+	undo.add(func() {
+		if err := container.UnmountWorkSpace(containerInfo.Mounts, containerInfo.Name); err != nil {
+			log.Errorf("Unmount workspace error: %v", err)
+		}
+	})
+	// a copy out of the stopped container finishes first
+	unlock, err := lockWorkspace(containerInfo.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = container.MountWorkSpace(containerInfo.Mounts, containerInfo.Image, containerInfo.Name)
+	unlock()
+	if err != nil {
+		return nil, fmt.Errorf("Mount workspace error: %v", err)
+	}
+	parent, initPipe, logFile, err := container.NewParentProcess(containerInfo)
+	if err != nil {
+		return nil, fmt.Errorf("New parent process error: %v", err)
+	}
+	undo.add(initPipe.Close)
+	if logFile != nil {
+		undo.add(func() { logFile.Close() })
+	}
+	if err := parent.Start(); err != nil {
+		return nil, err
+	}
+	initPipe.CloseChildFiles()
+	// init has its own copy, the monitor outlives many restarts
+	if logFile != nil {
+		logFile.Close()
+	}
+	undo.add(func() {
+		parent.Process.Kill()
+		parent.Wait()
+	})
+
+	// the cgroup stays until the container is removed
+	res := containerInfo.Resources
+	if res == nil {
+		res = &subsystems.ResourceConfig{}
+	}
+	// Set resources limitation
+	if err := cgroupManager.Set(res); err != nil {
+		return nil, err
+	}
+	// Add container process into each cgroup
+	if err := cgroupManager.Apply(parent.Process.Pid, res); err != nil {
+		return nil, err
+	}
+
+	containerInfo.Pid = parent.Process.Pid
+	containerInfo.PidStartTime, _ = container.ProcessStartTime(parent.Process.Pid)
+	if containerInfo.Network != "" {
+		// config container network
+		network.Init()
+		// a half configured endpoint is deleted too
+		undo.add(func() {
+			if containerInfo.IPAddress != nil {
+				network.Disconnect(containerInfo.Network, containerInfo)
+				containerInfo.IPAddress = nil
+			}
+		})
+		if _, err := network.Connect(containerInfo.Network, containerInfo); err != nil {
+			return nil, fmt.Errorf("Connect network %s error: %v", containerInfo.Network, err)
+		}
+	}
+	containerInfo.Status = container.RUNNING
+	containerInfo.MonitorPid = os.Getpid()
+	containerInfo.MonitorStartTime, _ = container.ProcessStartTime(os.Getpid())
+	containerInfo.StartedAt = time.Now().Format(time.RFC3339Nano)
+	containerInfo.FinishedAt = ""
+	containerInfo.ExitCode = 0
+	containerInfo.OOMKilled = false
+	containerInfo.Health = nil
+	if containerInfo.Healthcheck != nil {
+		containerInfo.Health = &container.Health{Status: container.HealthStarting}
+	}
+	if err := recordContainerInfo(containerInfo); err != nil {
+		return nil, fmt.Errorf("Record container info error: %v", err)
+	}
+
+	// initialize the container, init reports what keeps it from running
+	if err := initPipe.Send(container.NewInitSpec(containerInfo)); err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// recordContainerExit releases the network of an exited container and
+// records its exit code, it is marked restarting if its policy says so
+func recordContainerExit(id string, state *os.ProcessState, oomKilled bool) error {
+	return updateContainerInfoByID(id, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Network != "" && containerInfo.IPAddress != nil {
+			network.Init()
+			if err := network.Disconnect(containerInfo.Network, containerInfo); err != nil {
+				log.Errorf("Disconnect container %s error: %v", containerInfo.Name, err)
+			}
+			containerInfo.IPAddress = nil
+		}
+		containerInfo.Status = container.Exit
+		containerInfo.Pid = 0
+		containerInfo.ExitCode = exitCode(state)
+		containerInfo.FinishedAt = time.Now().Format(time.RFC3339Nano)
+		containerInfo.OOMKilled = oomKilled
+		log.Infof("Container %s exited with code %d", containerInfo.Name, containerInfo.ExitCode)
+		if containerInfo.RestartPolicy.ShouldRestart(containerInfo.ExitCode, containerInfo.RestartCount, containerInfo.ManuallyStopped) {
+			containerInfo.Status = container.RESTARTING
+		}
+		return nil
+	})
+}
+
+// exitCode follows the shell convention of 128+n for a process killed by signal n
+func exitCode(state *os.ProcessState) int {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return -1
+	}
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
+// startShim starts the monitor of a detached container and waits until
+// the container has been launched
+func startShim(containerInfo *container.ContainerInfo) error {
+	readPipe, writePipe, err := container.NewPipe()
+	if err != nil {
+		return err
+	}
+	defer readPipe.Close()
+	containerInfoDir := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Name)
+	logFile, err := os.OpenFile(path.Join(containerInfoDir, container.ShimLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		writePipe.Close()
+		return err
+	}
+	cmd := exec.Command("/proc/self/exe", "--root", container.RootDir, "shim", containerInfo.Name)
+	// the shim outlives this process and its terminal
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.ExtraFiles = []*os.File{writePipe}
+	err = cmd.Start()
+	writePipe.Close()
+	logFile.Close()
+	if err != nil {
+		return fmt.Errorf("Start shim error: %v", err)
+	}
+	cmd.Process.Release()
+
+	msg, _ := ioutil.ReadAll(readPipe)
+	switch string(msg) {
+	case shimReady:
+		return nil
+	case "":
+		return fmt.Errorf("Shim of container %s exited unexpectedly, see %s", containerInfo.Name, path.Join(containerInfoDir, container.ShimLogFile))
+	default:
+		return fmt.Errorf("%s", msg)
+	}
+}
+
+// containerCgroupManager manages the cgroup holding every process of a container
+func containerCgroupManager(containerInfo *container.ContainerInfo) *cgroups.CgroupManager {
+	return cgroups.NewCgroupManager(path.Join("mydocker", containerInfo.Id))
+}
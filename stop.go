@@ -1,58 +1,197 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/seagullbird/mydocker/container"
 	"github.com/seagullbird/mydocker/network"
-	"io/ioutil"
+	"github.com/seagullbird/mydocker/volume"
 	"os"
-	"path/filepath"
-	"strconv"
 	"syscall"
+	"time"
 )
 
-func stopContainer(containerName string) {
-	containerInfo, err := GetContainerInfoByName(containerName)
+const (
+	defaultStopTimeout = 10 * time.Second
+	// how long a SIGKILLed process may take to go away
+	killTimeout = 10 * time.Second
+	// how long the monitor may take to record an exit
+	monitorTimeout = 2 * time.Second
+)
+
+// stopContainer sends the stop signal of a running container and waits up
+// to timeout for it to exit before killing it. Its monitor records the
+// exit once the process has gone.
+func stopContainer(containerName string, timeout time.Duration) error {
+	var containerInfo *container.ContainerInfo
+	// a stopped container is not restarted by its restart policy
+	err := updateContainerInfo(containerName, func(info *container.ContainerInfo) error {
+		info.ManuallyStopped = true
+		if info.Status == container.RESTARTING {
+			info.Status = container.Exit
+		}
+		containerInfo = info
+		return nil
+	})
 	if err != nil {
-		log.Errorf("Get container %s info error %v", containerName, err)
-		return
+		return fmt.Errorf("Get container %s info error: %v", containerName, err)
 	}
-	pid, _ := strconv.Atoi(containerInfo.Pid)
-	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
-		log.Errorf("Stop container %s error %v", containerName, err)
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		return nil
 	}
-	containerInfo.Status = container.STOP
-	containerInfo.Pid = " "
-	newContentBytes, err := json.Marshal(containerInfo)
-	if err != nil {
-		log.Errorf("Json marshal %s error %v", containerName, err)
-		return
+	signal := syscall.SIGTERM
+	if containerInfo.StopSignal != "" {
+		if signal, err = container.ParseSignal(containerInfo.StopSignal); err != nil {
+			return err
+		}
+	}
+	pid := containerInfo.Pid
+	// a reused pid belongs to somebody else
+	if initAlive(containerInfo) {
+		if err := syscall.Kill(pid, signal); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("Stop container %s error: %v", containerName, err)
+		}
+		if containerInfo.Status == container.PAUSED {
+			// frozen processes cannot handle the signal
+			if err := containerCgroupManager(containerInfo).Thaw(); err != nil {
+				return fmt.Errorf("Thaw container %s error: %v", containerName, err)
+			}
+		}
+		if !container.WaitProcessExit(pid, timeout) {
+			log.Infof("Container %s did not exit within %v, killing it", containerName, timeout)
+			if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+				return fmt.Errorf("Kill container %s error: %v", containerName, err)
+			}
+			if !container.WaitProcessExit(pid, killTimeout) {
+				return fmt.Errorf("Container %s did not exit after SIGKILL", containerName)
+			}
+		}
 	}
-	containerInfoDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := filepath.Join(containerInfoDir, container.ConfigName)
-	if err := ioutil.WriteFile(configFilePath, newContentBytes, 0622); err != nil {
-		log.Errorf("Write file %s error", configFilePath, err)
+	return markContainerExited(containerName)
+}
+
+// markContainerExited waits for the monitor of a container whose process
+// has gone to record its exit, and marks it stopped if no monitor does
+func markContainerExited(containerName string) error {
+	deadline := time.Now().Add(monitorTimeout)
+	for time.Now().Before(deadline) {
+		containerInfo, err := GetContainerInfoByName(containerName)
+		if os.IsNotExist(err) {
+			// removed by its monitor with --rm
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Get container %s info error: %v", containerName, err)
+		}
+		if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
-	log.Infof("Updating container %s status to STOP.", containerName)
+	return updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+			return nil
+		}
+		log.Warnf("No monitor recorded the exit of container %s", containerName)
+		containerInfo.Status = container.STOP
+		containerInfo.Pid = 0
+		return nil
+	})
 }
 
-func removeContainer(containerName string) {
+func restartContainer(containerName string, timeout time.Duration) error {
+	if err := stopContainer(containerName, timeout); err != nil {
+		return err
+	}
+	return startContainerByName(containerName)
+}
+
+// removeContainer destroys a stopped container. With force a running
+// container is killed first, with removeVolumes its anonymous volumes go too.
+func removeContainer(containerName string, force, removeVolumes bool) error {
 	containerInfo, err := GetContainerInfoByName(containerName)
 	if err != nil {
-		log.Errorf("Get container %s info error %v", containerName, err)
-		return
+		return fmt.Errorf("Get container %s info error: %v", containerName, err)
+	}
+	if containerInfo.Status == container.RUNNING || containerInfo.Status == container.PAUSED || containerInfo.Status == container.RESTARTING {
+		if !force {
+			return fmt.Errorf("Cannot remove %s container %s, stop it first or use -f", containerInfo.Status, containerName)
+		}
+		if err := stopContainer(containerName, 0); err != nil {
+			return err
+		}
+		if containerInfo.AutoRemove {
+			// its monitor removes it
+			return waitContainerRemoved(containerName)
+		}
+		if containerInfo, err = GetContainerInfoByName(containerName); err != nil {
+			return fmt.Errorf("Get container %s info error: %v", containerName, err)
+		}
 	}
-	if containerInfo.Status != container.STOP {
-		log.Errorf("Cannot remove unstopped container")
+	if err := destroyContainer(containerInfo); err != nil {
+		return err
+	}
+	if removeVolumes {
+		removeAnonymousVolumes(containerInfo)
+	}
+	return nil
+}
+
+func waitContainerRemoved(containerName string) error {
+	deadline := time.Now().Add(monitorTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := GetContainerInfoByName(containerName); os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("Container %s has not been removed by its monitor", containerName)
+}
+
+// destroyContainer releases everything held by a container which is not
+// running. The container is kept when its workspace cannot be deleted.
+func destroyContainer(containerInfo *container.ContainerInfo) error {
+	containerName := containerInfo.Name
+	if len(containerInfo.Mounts) > 0 {
+		if err := volume.Init(); err != nil {
+			return err
+		}
+	}
+	if err := container.DeleteWorkSpace(containerInfo.Mounts, containerName, containerInfo.Image); err != nil {
+		return fmt.Errorf("Delete workspace of container %s error: %v", containerName, err)
+	}
+	// the monitor has released the address of an exited container
+	if containerInfo.Network != "" && containerInfo.IPAddress != nil {
+		network.Init()
+		network.Disconnect(containerInfo.Network, containerInfo)
+	}
+	deleteContainerInfo(containerName)
+	containerCgroupManager(containerInfo).Destroy()
+	for _, m := range containerInfo.Mounts {
+		if err := volume.Detach(m, containerInfo.Id); err != nil {
+			log.Errorf("Detach volume %s error: %v", m.Destination, err)
+		}
+	}
+	return nil
+}
+
+// removeAnonymousVolumes removes the anonymous volumes of a destroyed
+// container, unless other containers got them with --volumes-from
+func removeAnonymousVolumes(containerInfo *container.ContainerInfo) {
+	if err := volume.Init(); err != nil {
+		log.Errorf("Remove anonymous volumes error: %v", err)
 		return
 	}
-	network.Init()
-	network.Disconnect(containerInfo.Network, containerInfo)
-	containerInfoDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	if err := os.RemoveAll(containerInfoDir); err != nil {
-		log.Errorf("Remove container %s info error: %v", containerName, err)
+	for _, m := range containerInfo.Mounts {
+		if !m.Anonymous {
+			continue
+		}
+		if user := volumeUser(m.Name, containerInfo.Id); user != "" {
+			log.Warnf("Volume %s is in use by container %s, keeping it", m.Name, user)
+			continue
+		}
+		if err := volume.RemoveVolume(m.Name); err != nil {
+			log.Errorf("Remove volume %s error: %v", m.Name, err)
+		}
 	}
-	container.DeleteWorkSpace(containerInfo.Volume, containerName, containerInfo.Image)
 }
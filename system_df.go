@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+	"github.com/seagullbird/mydocker/volume"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+)
+
+type inodeKey struct {
+	dev uint64
+	ino uint64
+}
+
+// diskUsage walks a directory tree once. It counts allocated blocks like du,
+// and every inode only once per tree.
+type diskUsage struct {
+	size   int64
+	inodes map[inodeKey]int64
+}
+
+func walkDiskUsage(root string) (*diskUsage, error) {
+	du := &diskUsage{inodes: map[inodeKey]int64{}}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		key := inodeKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}
+		if _, seen := du.inodes[key]; seen {
+			return nil
+		}
+		size := stat.Blocks * 512
+		du.inodes[key] = size
+		du.size += size
+		return nil
+	})
+	return du, err
+}
+
+func fileSize(path string) int64 {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return 0
+	}
+	return stat.Blocks * 512
+}
+
+func humanSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1000 && i < len(units)-1 {
+		value /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%.3g%s", value, units[i])
+}
+
+func reclaimable(size, total int64) string {
+	percent := 0
+	if total > 0 {
+		percent = int(size * 100 / total)
+	}
+	return fmt.Sprintf("%s (%d%%)", humanSize(size), percent)
+}
+
+type imageUsage struct {
+	name       string
+	containers int
+	size       int64
+	shared     int64
+}
+
+type containerUsage struct {
+	info    *container.ContainerInfo
+	size    int64
+	logSize int64
+}
+
+type volumeUsage struct {
+	name  string
+	links int
+	size  int64
+}
+
+func systemDiskUsage(verbose bool) error {
+	containerInfos := loadContainerInfos()
+	imageContainers := map[string]int{}
+	volumeLinks := map[string]int{}
+	isContainer := map[string]bool{}
+	for _, info := range containerInfos {
+		isContainer[info.Name] = true
+		imageContainers[info.Image]++
+		for _, m := range info.Mounts {
+			if m.Type == container.MountTypeVolume {
+				volumeLinks[m.Name]++
+			}
+		}
+	}
+
+	// images: every layer under overlay2 not belonging to a container
+	layersDir := filepath.Dir(strings.TrimSuffix(container.LayerDir, "/"))
+	layers, err := ioutil.ReadDir(layersDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var images []*imageUsage
+	var imageDus []*diskUsage
+	inodeImages := map[inodeKey]int{}
+	for _, layer := range layers {
+		if !layer.IsDir() || isContainer[layer.Name()] {
+			continue
+		}
+		if exists, _ := container.PathExists(filepath.Join(layersDir, layer.Name(), "write_layer")); exists {
+			// workspace of a container whose info is gone
+			continue
+		}
+		du, err := walkDiskUsage(filepath.Join(layersDir, layer.Name()))
+		if err != nil {
+			log.Errorf("Disk usage of image %s error: %v", layer.Name(), err)
+			continue
+		}
+		for key := range du.inodes {
+			inodeImages[key]++
+		}
+		images = append(images, &imageUsage{
+			name:       layer.Name(),
+			containers: imageContainers[layer.Name()],
+			size:       du.size,
+		})
+		imageDus = append(imageDus, du)
+	}
+	var imagesTotal, imagesReclaimable int64
+	activeImages := 0
+	counted := map[inodeKey]bool{}
+	for i, image := range images {
+		for key, size := range imageDus[i].inodes {
+			// hard linked into other image layers
+			if inodeImages[key] > 1 {
+				image.shared += size
+			}
+			if !counted[key] {
+				counted[key] = true
+				imagesTotal += size
+			}
+		}
+		if image.containers > 0 {
+			activeImages++
+		} else {
+			imagesReclaimable += image.size - image.shared
+		}
+	}
+
+	// containers: their write layers and logs
+	var containers []*containerUsage
+	var containersTotal, containersReclaimable, logsTotal int64
+	activeContainers := 0
+	for _, info := range containerInfos {
+		du, err := walkDiskUsage(container.ContainerWriteLayerPath(info.Name))
+		if err != nil {
+			log.Errorf("Disk usage of container %s error: %v", info.Name, err)
+			continue
+		}
+		logSize := fileSize(filepath.Join(fmt.Sprintf(container.DefaultInfoLocation, info.Name), container.ContainerLogFile))
+		containers = append(containers, &containerUsage{info: info, size: du.size, logSize: logSize})
+		containersTotal += du.size
+		logsTotal += logSize
+		if info.Status == container.RUNNING || info.Status == container.PAUSED {
+			activeContainers++
+		} else {
+			containersReclaimable += du.size
+		}
+	}
+
+	// volumes: only local ones live under the root
+	localDriver := &volume.LocalVolumeDriver{}
+	volumeNames, err := localDriver.List()
+	if err != nil {
+		return err
+	}
+	var volumes []*volumeUsage
+	var volumesTotal, volumesReclaimable int64
+	activeVolumes := 0
+	for _, name := range volumeNames {
+		volumePath, _ := localDriver.Path(name)
+		du, err := walkDiskUsage(volumePath)
+		if err != nil {
+			log.Errorf("Disk usage of volume %s error: %v", name, err)
+			continue
+		}
+		volumes = append(volumes, &volumeUsage{name: name, links: volumeLinks[name], size: du.size})
+		volumesTotal += du.size
+		if volumeLinks[name] > 0 {
+			activeVolumes++
+		} else {
+			volumesReclaimable += du.size
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	if !verbose {
+		fmt.Fprint(w, "TYPE\tTOTAL\tACTIVE\tSIZE\tRECLAIMABLE\n")
+		fmt.Fprintf(w, "Images\t%d\t%d\t%s\t%s\n", len(images), activeImages,
+			humanSize(imagesTotal), reclaimable(imagesReclaimable, imagesTotal))
+		fmt.Fprintf(w, "Containers\t%d\t%d\t%s\t%s\n", len(containers), activeContainers,
+			humanSize(containersTotal), reclaimable(containersReclaimable, containersTotal))
+		fmt.Fprintf(w, "Local Volumes\t%d\t%d\t%s\t%s\n", len(volumes), activeVolumes,
+			humanSize(volumesTotal), reclaimable(volumesReclaimable, volumesTotal))
+		fmt.Fprintf(w, "Logs\t%d\t\t%s\t\n", len(containers), humanSize(logsTotal))
+		return w.Flush()
+	}
+
+	sort.Slice(images, func(i, j int) bool { return images[i].name < images[j].name })
+	fmt.Fprint(w, "Images space usage:\n\n")
+	fmt.Fprint(w, "IMAGE\tCONTAINERS\tSIZE\tSHARED SIZE\tUNIQUE SIZE\n")
+	for _, image := range images {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", image.name, image.containers,
+			humanSize(image.size), humanSize(image.shared), humanSize(image.size-image.shared))
+	}
+	fmt.Fprint(w, "\nContainers space usage:\n\n")
+	fmt.Fprint(w, "ID\tNAME\tIMAGE\tSTATUS\tSIZE\tLOG SIZE\n")
+	for _, c := range containers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", shortID(c.info.Id), c.info.Name, c.info.Image,
+			c.info.Status, humanSize(c.size), humanSize(c.logSize))
+	}
+	fmt.Fprint(w, "\nLocal Volumes space usage:\n\n")
+	fmt.Fprint(w, "VOLUME NAME\tLINKS\tSIZE\n")
+	for _, v := range volumes {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", v.name, v.links, humanSize(v.size))
+	}
+	return w.Flush()
+}
@@ -1,13 +1,60 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/seagullbird/mydocker/container"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
+// resolveContainerName finds the name of a container referred to by its
+// name, its ID or an unambiguous prefix of its ID
+func resolveContainerName(ref string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("Empty container name")
+	}
+	if _, err := os.Stat(filepath.Join(fmt.Sprintf(container.DefaultInfoLocation, ref), container.ConfigName)); err == nil {
+		return ref, nil
+	}
+	var matches []string
+	for _, containerInfo := range loadContainerInfos() {
+		if strings.HasPrefix(containerInfo.Id, ref) {
+			matches = append(matches, containerInfo.Name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("No such container: %s", ref)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("Multiple IDs found with provided prefix: %s", ref)
+}
+
+// newContainerID returns a random 64 hex digits ID
+func newContainerID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// shortID is the ID shown by ps
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
 func GetContainerInfoByName(containerName string) (*container.ContainerInfo, error) {
 	containerInfoDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
 	configPath := filepath.Join(containerInfoDir, container.ConfigName)
@@ -15,9 +62,111 @@ func GetContainerInfoByName(containerName string) (*container.ContainerInfo, err
 	if err != nil {
 		return nil, err
 	}
+	return decodeContainerInfo(contentBytes)
+}
+
+// decodeContainerInfo reads a record, bringing records written by older
+// versions up to date
+func decodeContainerInfo(content []byte) (*container.ContainerInfo, error) {
 	var containerInfo container.ContainerInfo
-	if err := json.Unmarshal(contentBytes, &containerInfo); err != nil {
+	// records written before the pid was a number hold it as a string,
+	// " " once the container has exited
+	record := struct {
+		*container.ContainerInfo
+		Pid json.RawMessage `json:"pid"`
+	}{ContainerInfo: &containerInfo}
+	if err := json.Unmarshal(content, &record); err != nil {
 		return nil, err
 	}
+	if len(record.Pid) > 0 {
+		var legacyPid string
+		if err := json.Unmarshal(record.Pid, &legacyPid); err == nil {
+			containerInfo.Pid, _ = strconv.Atoi(strings.TrimSpace(legacyPid))
+		} else if err := json.Unmarshal(record.Pid, &containerInfo.Pid); err != nil {
+			return nil, fmt.Errorf("Read pid of container %s error: %v", containerInfo.Name, err)
+		}
+	}
+	if containerInfo.Volume != "" && len(containerInfo.Mounts) == 0 {
+		m, err := legacyMount(containerInfo.Volume, containerInfo.VolumeDriver)
+		if err != nil {
+			return nil, fmt.Errorf("Read volume %s of container %s error: %v", containerInfo.Volume, containerInfo.Name, err)
+		}
+		containerInfo.Mounts = []*container.Mount{m}
+	}
+	containerInfo.Volume, containerInfo.VolumeDriver = "", ""
 	return &containerInfo, nil
 }
+
+// updateContainerInfo applies update to the recorded info of a container.
+// The monitor of a container records its exit concurrently with commands
+// changing its status, the record is locked meanwhile.
+func updateContainerInfo(containerName string, update func(*container.ContainerInfo) error) error {
+	containerInfoDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	lock, err := os.OpenFile(filepath.Join(containerInfoDir, container.ConfigLockName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	containerInfo, err := GetContainerInfoByName(containerName)
+	if err != nil {
+		return err
+	}
+	if err := update(containerInfo); err != nil {
+		return err
+	}
+	return recordContainerInfo(containerInfo)
+}
+
+// lockWorkspace serializes mounting the workspace of a container. cp holds
+// it while the workspace of a stopped container is mounted for a copy.
+func lockWorkspace(containerName string) (func(), error) {
+	containerInfoDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	lock, err := os.OpenFile(filepath.Join(containerInfoDir, container.WorkspaceLockName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}, nil
+}
+
+// errContainerRenamed tells that a record changed hands while being locked
+var errContainerRenamed = fmt.Errorf("Container has been renamed")
+
+// updateContainerInfoByID is updateContainerInfo for the monitor, which
+// knows its container by ID as the container may be renamed meanwhile
+func updateContainerInfoByID(id string, update func(*container.ContainerInfo) error) error {
+	for {
+		containerInfo, err := getContainerInfoByID(id)
+		if err != nil {
+			return err
+		}
+		err = updateContainerInfo(containerInfo.Name, func(containerInfo *container.ContainerInfo) error {
+			if containerInfo.Id != id {
+				return errContainerRenamed
+			}
+			return update(containerInfo)
+		})
+		if err != errContainerRenamed && !os.IsNotExist(err) {
+			return err
+		}
+	}
+}
+
+func getContainerInfoByID(id string) (*container.ContainerInfo, error) {
+	for _, containerInfo := range loadContainerInfos() {
+		if containerInfo.Id == id {
+			return containerInfo, nil
+		}
+	}
+	return nil, fmt.Errorf("No such container: %s", id)
+}
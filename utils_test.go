@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestResolveContainerName(t *testing.T) {
+	defer setTempRoot(t)()
+	addContainer(t, "abc123", "web")
+	addContainer(t, "abd456", "db")
+	// a name looking like the ID prefix of another container
+	addContainer(t, "fff000", "abd")
+
+	cases := []struct {
+		ref  string
+		want string
+	}{
+		{"web", "web"},
+		{"db", "db"},
+		{"abc", "web"},
+		{"abc123", "web"},
+		{"abd4", "db"},
+		{"fff", "abd"},
+		// names win over ID prefixes
+		{"abd", "abd"},
+	}
+	for _, c := range cases {
+		got, err := resolveContainerName(c.ref)
+		if err != nil {
+			t.Errorf("resolveContainerName(%q) error: %v", c.ref, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("resolveContainerName(%q) = %s, want %s", c.ref, got, c.want)
+		}
+	}
+	// ambiguous, unknown and empty references
+	for _, ref := range []string{"ab", "a", "zzz", "abc1234", ""} {
+		if got, err := resolveContainerName(ref); err == nil {
+			t.Errorf("resolveContainerName(%q) = %s, should fail", ref, got)
+		}
+	}
+}
+
+func TestDecodeContainerInfoPid(t *testing.T) {
+	cases := map[string]int{
+		`{"pid":4242,"name":"web"}`:   4242,
+		`{"pid":"4242","name":"web"}`: 4242,
+		// exited containers of older records
+		`{"pid":" ","name":"web"}`: 0,
+		`{"pid":"","name":"web"}`:  0,
+		`{"name":"web"}`:           0,
+	}
+	for content, want := range cases {
+		info, err := decodeContainerInfo([]byte(content))
+		if err != nil {
+			t.Errorf("decodeContainerInfo(%s) error: %v", content, err)
+			continue
+		}
+		if info.Pid != want || info.Name != "web" {
+			t.Errorf("decodeContainerInfo(%s) = pid %d name %s, want pid %d name web", content, info.Pid, info.Name, want)
+		}
+	}
+	if _, err := decodeContainerInfo([]byte(`{"pid":[1],"name":"web"}`)); err == nil {
+		t.Error("decodeContainerInfo with an invalid pid should fail")
+	}
+}
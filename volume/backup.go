@@ -0,0 +1,78 @@
+package volume
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// ownership, modes, xattrs, ACLs and holes of sparse files survive a
+// round trip through ExportVolume and ImportVolume
+var tarArchiveFlags = []string{"--numeric-owner", "--xattrs", "--xattrs-include=*", "--acls"}
+
+// ExportVolume writes the content of a volume to w as a tar stream
+func ExportVolume(name string, w io.Writer) error {
+	vol, ok := volumes[name]
+	if !ok {
+		return fmt.Errorf("No such volume: %s", name)
+	}
+	d, err := getDriver(vol.Driver)
+	if err != nil {
+		return err
+	}
+	return withVolumePath(d, name, func(volumePath string) error {
+		args := append([]string{"-C", volumePath, "--sparse"}, tarArchiveFlags...)
+		args = append(args, "-cf", "-", ".")
+		return runTar(args, nil, w)
+	})
+}
+
+// ImportVolume extracts the tar stream r into a volume, creating it with
+// driverName first when it does not exist yet
+func ImportVolume(driverName, name string, r io.Reader) error {
+	vol, ok := volumes[name]
+	if !ok {
+		if err := CreateVolume(driverName, name, nil); err != nil {
+			return err
+		}
+		vol = volumes[name]
+	} else if driverName != "" && driverName != vol.Driver {
+		return fmt.Errorf("Volume %s already exists with driver %s", name, vol.Driver)
+	}
+	d, err := getDriver(vol.Driver)
+	if err != nil {
+		return err
+	}
+	return withVolumePath(d, name, func(volumePath string) error {
+		args := append([]string{"-C", volumePath, "--same-owner", "--same-permissions"}, tarArchiveFlags...)
+		args = append(args, "-xf", "-")
+		return runTar(args, r, nil)
+	})
+}
+
+// withVolumePath mounts the volume through its driver for the duration of fn
+func withVolumePath(d VolumeDriver, name string, fn func(string) error) error {
+	id := "backup-" + strconv.Itoa(os.Getpid())
+	volumePath, err := d.Mount(name, id)
+	if err != nil {
+		return fmt.Errorf("Error mounting volume %s: %v", name, err)
+	}
+	err = fn(volumePath)
+	if unmountErr := d.Unmount(name, id); err == nil && unmountErr != nil {
+		err = fmt.Errorf("Error unmounting volume %s: %v", name, unmountErr)
+	}
+	return err
+}
+
+func runTar(args []string, stdin io.Reader, stdout io.Writer) error {
+	cmd := exec.Command("tar", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("tar error: %v", err)
+	}
+	return nil
+}
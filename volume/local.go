@@ -0,0 +1,66 @@
+package volume
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// set by SetRootDir
+var localDataDir string
+
+// LocalVolumeDriver keeps named volumes as directories under localDataDir
+// and hands out absolute host paths as they are
+type LocalVolumeDriver struct {
+}
+
+func (d *LocalVolumeDriver) Name() string {
+	return "local"
+}
+
+func (d *LocalVolumeDriver) Create(name string, opts map[string]string) error {
+	volumePath, _ := d.Path(name)
+	return os.MkdirAll(volumePath, 0755)
+}
+
+func (d *LocalVolumeDriver) Remove(name string) error {
+	volumePath, _ := d.Path(name)
+	return os.RemoveAll(volumePath)
+}
+
+func (d *LocalVolumeDriver) Mount(name, id string) (string, error) {
+	volumePath, _ := d.Path(name)
+	if err := os.MkdirAll(volumePath, 0777); err != nil {
+		return "", err
+	}
+	return volumePath, nil
+}
+
+func (d *LocalVolumeDriver) Unmount(name, id string) error {
+	return nil
+}
+
+func (d *LocalVolumeDriver) Path(name string) (string, error) {
+	// a host path is used in place
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+	return filepath.Join(localDataDir, name), nil
+}
+
+func (d *LocalVolumeDriver) List() ([]string, error) {
+	files, err := ioutil.ReadDir(localDataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		if file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,130 @@
+package volume
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Plugins listen on <PluginDir>/<driver name>.sock and serve
+// POST /VolumeDriver.<Method> with a JSON PluginRequest body,
+// answering with a JSON PluginResponse whose Err is empty on success.
+// Methods are Create, Remove, Mount, Unmount, Path and List.
+const (
+	PluginDir         = "/run/mydocker/plugins"
+	PluginContentType = "application/vnd.mydocker.plugins.v1+json"
+)
+
+type PluginRequest struct {
+	Name string            `json:",omitempty"`
+	ID   string            `json:",omitempty"`
+	Opts map[string]string `json:",omitempty"`
+}
+
+type PluginVolume struct {
+	Name       string
+	Mountpoint string `json:",omitempty"`
+}
+
+type PluginResponse struct {
+	Mountpoint string          `json:",omitempty"`
+	Volumes    []*PluginVolume `json:",omitempty"`
+	Err        string
+}
+
+// PluginVolumeDriver forwards every call to an out-of-process plugin
+type PluginVolumeDriver struct {
+	name   string
+	client *http.Client
+}
+
+func NewPluginVolumeDriver(name, sockPath string) *PluginVolumeDriver {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sockPath)
+		},
+	}
+	return &PluginVolumeDriver{
+		name: name,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   30 * time.Second,
+		},
+	}
+}
+
+func (d *PluginVolumeDriver) Name() string {
+	return d.name
+}
+
+func (d *PluginVolumeDriver) Create(name string, opts map[string]string) error {
+	_, err := d.call("Create", &PluginRequest{Name: name, Opts: opts})
+	return err
+}
+
+func (d *PluginVolumeDriver) Remove(name string) error {
+	_, err := d.call("Remove", &PluginRequest{Name: name})
+	return err
+}
+
+func (d *PluginVolumeDriver) Mount(name, id string) (string, error) {
+	res, err := d.call("Mount", &PluginRequest{Name: name, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return res.Mountpoint, nil
+}
+
+func (d *PluginVolumeDriver) Unmount(name, id string) error {
+	_, err := d.call("Unmount", &PluginRequest{Name: name, ID: id})
+	return err
+}
+
+func (d *PluginVolumeDriver) Path(name string) (string, error) {
+	res, err := d.call("Path", &PluginRequest{Name: name})
+	if err != nil {
+		return "", err
+	}
+	return res.Mountpoint, nil
+}
+
+func (d *PluginVolumeDriver) List() ([]string, error) {
+	res, err := d.call("List", &PluginRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, vol := range res.Volumes {
+		names = append(names, vol.Name)
+	}
+	return names, nil
+}
+
+func (d *PluginVolumeDriver) call(method string, req *PluginRequest) (*PluginResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	// the host part is ignored, every request goes through the unix socket
+	resp, err := d.client.Post("http://plugin/VolumeDriver."+method, PluginContentType, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Volume plugin %s %s error: %v", d.name, method, err)
+	}
+	defer resp.Body.Close()
+
+	var res PluginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("Volume plugin %s %s bad response (%s): %v", d.name, method, resp.Status, err)
+	}
+	if res.Err != "" {
+		return nil, fmt.Errorf("Volume plugin %s %s error: %s", d.name, method, res.Err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Volume plugin %s %s error: %s", d.name, method, resp.Status)
+	}
+	return &res, nil
+}
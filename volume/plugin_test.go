@@ -0,0 +1,83 @@
+package volume
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func startFakePlugin(t *testing.T, handler func(method string, req *PluginRequest) *PluginResponse) (string, func()) {
+	dir, err := ioutil.TempDir("", "mydocker-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sockPath := filepath.Join(dir, "fake.sock")
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		var req PluginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(handler(r.URL.Path[len("/VolumeDriver."):], &req))
+	})
+	go http.Serve(l, mux)
+	return sockPath, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPluginVolumeDriver_Mount(t *testing.T) {
+	sockPath, stop := startFakePlugin(t, func(method string, req *PluginRequest) *PluginResponse {
+		if method != "Mount" || req.Name != "data" || req.ID != "c1" {
+			return &PluginResponse{Err: "unexpected " + method}
+		}
+		return &PluginResponse{Mountpoint: "/plugin/data"}
+	})
+	defer stop()
+
+	d := NewPluginVolumeDriver("fake", sockPath)
+	mountpoint, err := d.Mount("data", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mountpoint != "/plugin/data" {
+		t.Errorf("mountpoint = %s, want /plugin/data", mountpoint)
+	}
+}
+
+func TestPluginVolumeDriver_Error(t *testing.T) {
+	sockPath, stop := startFakePlugin(t, func(method string, req *PluginRequest) *PluginResponse {
+		return &PluginResponse{Err: "no space left"}
+	})
+	defer stop()
+
+	d := NewPluginVolumeDriver("fake", sockPath)
+	if err := d.Create("data", nil); err == nil {
+		t.Error("expected plugin error to be returned")
+	}
+}
+
+func TestPluginVolumeDriver_List(t *testing.T) {
+	sockPath, stop := startFakePlugin(t, func(method string, req *PluginRequest) *PluginResponse {
+		return &PluginResponse{Volumes: []*PluginVolume{{Name: "a"}, {Name: "b"}}}
+	})
+	defer stop()
+
+	d := NewPluginVolumeDriver("fake", sockPath)
+	names, err := d.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("names = %v, want [a b]", names)
+	}
+}
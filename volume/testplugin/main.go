@@ -0,0 +1,115 @@
+// testplugin is a minimal mydocker volume plugin keeping every volume
+// as a directory under -root. Start it, then use it with
+//
+//	mydocker run -v data:/data --volume-driver testplugin ...
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/seagullbird/mydocker/volume"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type dirDriver struct {
+	root string
+	sync.Mutex
+	// container ids each volume is mounted for
+	mounts map[string]map[string]bool
+}
+
+func (d *dirDriver) path(name string) string {
+	return filepath.Join(d.root, name)
+}
+
+func (d *dirDriver) serve(method string, req *volume.PluginRequest) (*volume.PluginResponse, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	res := &volume.PluginResponse{}
+	switch method {
+	case "Create":
+		return res, os.MkdirAll(d.path(req.Name), 0755)
+	case "Remove":
+		if len(d.mounts[req.Name]) > 0 {
+			return nil, fmt.Errorf("volume %s is in use", req.Name)
+		}
+		return res, os.RemoveAll(d.path(req.Name))
+	case "Mount":
+		if _, err := os.Stat(d.path(req.Name)); err != nil {
+			return nil, err
+		}
+		if d.mounts[req.Name] == nil {
+			d.mounts[req.Name] = map[string]bool{}
+		}
+		d.mounts[req.Name][req.ID] = true
+		res.Mountpoint = d.path(req.Name)
+	case "Unmount":
+		delete(d.mounts[req.Name], req.ID)
+	case "Path":
+		res.Mountpoint = d.path(req.Name)
+	case "List":
+		files, err := ioutil.ReadDir(d.root)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			res.Volumes = append(res.Volumes, &volume.PluginVolume{
+				Name:       file.Name(),
+				Mountpoint: d.path(file.Name()),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unknown method %s", method)
+	}
+	return res, nil
+}
+
+func (d *dirDriver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/VolumeDriver.")
+	var req volume.PluginRequest
+	res := &volume.PluginResponse{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res.Err = err.Error()
+	} else if res, err = d.serve(method, &req); err != nil {
+		res = &volume.PluginResponse{Err: err.Error()}
+	}
+	log.Printf("%s %q: %+v", method, req.Name, res)
+	w.Header().Set("Content-Type", volume.PluginContentType)
+	json.NewEncoder(w).Encode(res)
+}
+
+func main() {
+	name := flag.String("name", "testplugin", "driver name, the socket is created as <name>.sock")
+	root := flag.String("root", "/var/lib/mydocker-testplugin", "directory holding the volumes")
+	flag.Parse()
+
+	if err := os.MkdirAll(*root, 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(volume.PluginDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	sockPath := filepath.Join(volume.PluginDir, *name+".sock")
+	os.Remove(sockPath)
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(sockPath)
+
+	log.Printf("serving volume driver %s on %s", *name, sockPath)
+	d := &dirDriver{
+		root:   *root,
+		mounts: map[string]map[string]bool{},
+	}
+	log.Fatal(http.Serve(l, d))
+}
@@ -0,0 +1,365 @@
+package volume
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+type Volume struct {
+	// Name of the volume
+	Name string
+	// Name of the volume driver
+	Driver string
+	// Driver specific options the volume was created with
+	Options map[string]string
+}
+
+var (
+	volumeRootDir string
+	volumeInfoDir string
+	drivers       = map[string]VolumeDriver{}
+	volumes       = map[string]*Volume{}
+)
+
+func init() {
+	SetRootDir(container.RootDir)
+}
+
+// SetRootDir relocates volume state and local volumes under root
+func SetRootDir(root string) {
+	volumeRootDir = filepath.Join(root, "volume")
+	volumeInfoDir = filepath.Join(volumeRootDir, "volumes")
+	localDataDir = filepath.Join(volumeRootDir, "local")
+}
+
+const defaultDriver = "local"
+
+type VolumeDriver interface {
+	// returns the name of the driver
+	Name() string
+	// create a volume
+	Create(name string, opts map[string]string) error
+	// remove a volume
+	Remove(name string) error
+	// make a volume available for the container id, returns its host path
+	Mount(name, id string) (string, error)
+	// release a volume previously mounted for the container id
+	Unmount(name, id string) error
+	// returns the host path of a volume
+	Path(name string) (string, error)
+	// list the names of all volumes known by the driver
+	List() ([]string, error)
+}
+
+func (v *Volume) dump(dumpPath string) error {
+	if _, err := os.Stat(dumpPath); err != nil {
+		if os.IsNotExist(err) {
+			os.MkdirAll(dumpPath, 0644)
+		} else {
+			return err
+		}
+	}
+	volPath := filepath.Join(dumpPath, v.Name)
+	volFile, err := os.OpenFile(volPath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		log.Errorf("Open volume file %s error: %v", volPath, err)
+		return err
+	}
+	defer volFile.Close()
+
+	volJson, err := json.Marshal(v)
+	if err != nil {
+		log.Errorf("Marshal volume error: %v", err)
+		return err
+	}
+	if _, err = volFile.Write(volJson); err != nil {
+		log.Errorf("Write volume error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (v *Volume) load(loadPath string) error {
+	volJson, err := ioutil.ReadFile(loadPath)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(volJson, v); err != nil {
+		log.Errorf("Error load volume info from %s: %v", loadPath, err)
+		return err
+	}
+	return nil
+}
+
+func (v *Volume) remove(dumpPath string) error {
+	if _, err := os.Stat(filepath.Join(dumpPath, v.Name)); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return os.Remove(filepath.Join(dumpPath, v.Name))
+}
+
+func Init() error {
+	var localDriver = LocalVolumeDriver{}
+	drivers[localDriver.Name()] = &localDriver
+
+	if _, err := os.Stat(volumeInfoDir); err != nil {
+		if os.IsNotExist(err) {
+			os.MkdirAll(volumeInfoDir, 0644)
+		} else {
+			return err
+		}
+	}
+
+	// every file under volumeInfoDir describes one volume
+	err := filepath.Walk(volumeInfoDir, func(volPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		_, volName := path.Split(volPath)
+		vol := &Volume{
+			Name: volName,
+		}
+		if err := vol.load(volPath); err != nil {
+			log.Errorf("error load volume: %v", err)
+		}
+		volumes[volName] = vol
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Load volumes error: %v", err)
+	}
+
+	// register every plugin socket found under PluginDir
+	socks, _ := filepath.Glob(filepath.Join(PluginDir, "*.sock"))
+	for _, sockPath := range socks {
+		name := strings.TrimSuffix(filepath.Base(sockPath), ".sock")
+		if _, ok := drivers[name]; !ok {
+			drivers[name] = NewPluginVolumeDriver(name, sockPath)
+		}
+	}
+	return nil
+}
+
+// getDriver returns the driver registered under name, falling back to
+// a plugin listening on <PluginDir>/<name>.sock
+func getDriver(name string) (VolumeDriver, error) {
+	if name == "" {
+		name = defaultDriver
+	}
+	if d, ok := drivers[name]; ok {
+		return d, nil
+	}
+	sockPath := filepath.Join(PluginDir, name+".sock")
+	if _, err := os.Stat(sockPath); err != nil {
+		return nil, fmt.Errorf("No such volume driver: %s", name)
+	}
+	d := NewPluginVolumeDriver(name, sockPath)
+	drivers[name] = d
+	return d, nil
+}
+
+func CreateVolume(driverName, name string, opts map[string]string) error {
+	if _, ok := volumes[name]; ok {
+		return fmt.Errorf("Volume %s already exists", name)
+	}
+	if strings.ContainsAny(name, "/:") {
+		return fmt.Errorf("Invalid volume name: %s", name)
+	}
+	d, err := getDriver(driverName)
+	if err != nil {
+		return err
+	}
+	if err := d.Create(name, opts); err != nil {
+		return err
+	}
+	vol := &Volume{
+		Name:    name,
+		Driver:  d.Name(),
+		Options: opts,
+	}
+	volumes[name] = vol
+	return vol.dump(volumeInfoDir)
+}
+
+func RemoveVolume(name string) error {
+	vol, ok := volumes[name]
+	if !ok {
+		return fmt.Errorf("No such volume: %s", name)
+	}
+	d, err := getDriver(vol.Driver)
+	if err != nil {
+		return err
+	}
+	if err := d.Remove(name); err != nil {
+		return fmt.Errorf("Error removing volume %s from driver %s: %v", name, vol.Driver, err)
+	}
+	delete(volumes, name)
+	return vol.remove(volumeInfoDir)
+}
+
+func ListVolume() {
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "NAME\tDRIVER\tMOUNTPOINT\n")
+	for _, d := range drivers {
+		names, err := d.List()
+		if err != nil {
+			log.Errorf("List volumes of driver %s error: %v", d.Name(), err)
+			continue
+		}
+		for _, name := range names {
+			mountpoint, _ := d.Path(name)
+			fmt.Fprintf(w, "%s\t%s\t%s\n",
+				name,
+				d.Name(),
+				mountpoint,
+			)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Errorf("Flush error: %v", err)
+		return
+	}
+}
+
+// ParseMount parses a -v spec "src:dst[:ro|rw]". An absolute src is a
+// host path bind mounted as it is, anything else names a volume of
+// driverName. A spec "dst[:ro|rw]" without src gets an anonymous volume.
+func ParseMount(spec, driverName string) (*container.Mount, error) {
+	parts := strings.Split(spec, ":")
+	rw := true
+	if len(parts) == 1 || (len(parts) == 2 && (parts[1] == "ro" || parts[1] == "rw")) {
+		if !filepath.IsAbs(parts[0]) {
+			return nil, fmt.Errorf("Volume destination %s is not an absolute path", parts[0])
+		}
+		return &container.Mount{
+			Type:        container.MountTypeVolume,
+			Driver:      driverName,
+			Destination: filepath.Clean(parts[0]),
+			RW:          len(parts) == 1 || parts[1] == "rw",
+			Anonymous:   true,
+		}, nil
+	}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			rw = false
+		case "rw":
+		default:
+			return nil, fmt.Errorf("Invalid volume mode %s in %s", parts[2], spec)
+		}
+		parts = parts[:2]
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("Volume parameter input is not correct: %s", spec)
+	}
+	if !filepath.IsAbs(parts[1]) {
+		return nil, fmt.Errorf("Volume destination %s is not an absolute path", parts[1])
+	}
+	m := &container.Mount{
+		Destination: filepath.Clean(parts[1]),
+		RW:          rw,
+	}
+	if filepath.IsAbs(parts[0]) {
+		m.Type = container.MountTypeBind
+		m.Source = parts[0]
+	} else {
+		m.Type = container.MountTypeVolume
+		m.Name = parts[0]
+		m.Driver = driverName
+	}
+	return m, nil
+}
+
+// Attach resolves the source of m to a host path for the container.
+// Host paths are handled by the local driver, named volumes are created
+// on first use and mounted by their driver.
+func Attach(m *container.Mount, containerID string) error {
+	if m.Anonymous && m.Name == "" {
+		m.Name = anonymousVolumeName()
+	}
+	name := m.Name
+	if m.Type == container.MountTypeBind {
+		name = m.Source
+	}
+	d, err := mountDriver(m)
+	if err != nil {
+		return err
+	}
+	source, err := d.Mount(name, containerID)
+	if err != nil {
+		return fmt.Errorf("Error mounting volume %s: %v", name, err)
+	}
+	m.Source = source
+	return nil
+}
+
+// Locate fills in the host path of a mount attached by an older version,
+// which did not record it, without attaching it again
+func Locate(m *container.Mount) error {
+	d, err := mountDriver(m)
+	if err != nil {
+		return err
+	}
+	source, err := d.Path(m.Name)
+	if err != nil {
+		return fmt.Errorf("Error locating volume %s: %v", m.Name, err)
+	}
+	m.Source = source
+	return nil
+}
+
+// Detach releases a mount previously passed to Attach
+func Detach(m *container.Mount, containerID string) error {
+	if m.Type == container.MountTypeBind {
+		return nil
+	}
+	if _, ok := volumes[m.Name]; !ok {
+		// the volume has been removed already
+		return nil
+	}
+	d, err := mountDriver(m)
+	if err != nil {
+		return err
+	}
+	return d.Unmount(m.Name, containerID)
+}
+
+func anonymousVolumeName() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func mountDriver(m *container.Mount) (VolumeDriver, error) {
+	if m.Type == container.MountTypeBind {
+		return getDriver(defaultDriver)
+	}
+	vol, ok := volumes[m.Name]
+	if !ok {
+		if err := CreateVolume(m.Driver, m.Name, nil); err != nil {
+			return nil, err
+		}
+		vol = volumes[m.Name]
+	}
+	if m.Driver != "" && m.Driver != vol.Driver {
+		return nil, fmt.Errorf("Volume %s already exists with driver %s", m.Name, vol.Driver)
+	}
+	m.Driver = vol.Driver
+	return getDriver(vol.Driver)
+}
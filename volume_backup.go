@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/seagullbird/mydocker/container"
+	"github.com/seagullbird/mydocker/volume"
+	"io"
+	"os"
+)
+
+// exportVolume writes a tar snapshot of a volume to output, "-" for stdout.
+// With pause the running containers using the volume are frozen meanwhile.
+func exportVolume(name, output string, pause bool) error {
+	if err := volume.Init(); err != nil {
+		return err
+	}
+	if pause {
+		paused, err := pauseVolumeUsers(name)
+		defer resumeContainers(paused)
+		if err != nil {
+			return err
+		}
+	}
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return volume.ExportVolume(name, w)
+}
+
+// importVolume restores a tar snapshot from input, "-" for stdin
+func importVolume(driverName, name, input string) error {
+	if err := volume.Init(); err != nil {
+		return err
+	}
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return volume.ImportVolume(driverName, name, r)
+}
+
+func pauseVolumeUsers(name string) ([]*container.ContainerInfo, error) {
+	var paused []*container.ContainerInfo
+	for _, info := range loadContainerInfos() {
+		// already paused containers stay paused
+		if info.Status != container.RUNNING || !usesVolume(info, name) {
+			continue
+		}
+		log.Infof("Pausing container %s", info.Name)
+		if err := containerCgroupManager(info).Freeze(); err != nil {
+			return paused, fmt.Errorf("Pause container %s error: %v", info.Name, err)
+		}
+		paused = append(paused, info)
+	}
+	return paused, nil
+}
+
+func resumeContainers(containerInfos []*container.ContainerInfo) {
+	for _, info := range containerInfos {
+		if err := containerCgroupManager(info).Thaw(); err != nil {
+			log.Errorf("Resume container %s error: %v", info.Name, err)
+		}
+	}
+}
+
+// volumeUser returns the name of a container other than id using the
+// volume, empty when there is none
+func volumeUser(name, id string) string {
+	for _, info := range loadContainerInfos() {
+		if info.Id != id && usesVolume(info, name) {
+			return info.Name
+		}
+	}
+	return ""
+}
+
+func usesVolume(info *container.ContainerInfo, name string) bool {
+	for _, m := range info.Mounts {
+		if m.Type == container.MountTypeVolume && m.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"github.com/seagullbird/mydocker/container"
+	"os"
+	"time"
+)
+
+// waitContainer blocks until a running or paused container has exited and
+// returns the exit code recorded by its monitor
+func waitContainer(containerName string) (int, error) {
+	for seen := false; ; seen = true {
+		containerInfo, err := GetContainerInfoByName(containerName)
+		if seen && os.IsNotExist(err) {
+			return 0, fmt.Errorf("Container %s has been removed, its exit code is lost", containerName)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Get container %s info error: %v", containerName, err)
+		}
+		if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+			return containerInfo.ExitCode, nil
+		}
+		if isStale(containerInfo) {
+			// nobody is left to record its exit
+			if err := reconcileContainer(containerName); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}